@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"unicode"
+)
+
+// splitArgs splits a command line options string into arguments, like a shell would:
+// arguments are separated by whitespace, single and double quotes group, backslash escapes the next character
+// (in single quotes it is kept as is).
+func splitArgs(s string) ([]string, error) {
+	args := []string{}
+
+	var current bytes.Buffer
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if escaped {
+		return nil, fmt.Errorf("unterminated escape in: %s", s)
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in: %s", quote, s)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    []string
+		wantErr bool
+	}{
+		{s: "", want: []string{}},
+		{s: "  \t\n ", want: []string{}},
+		{s: "/verbosity:minimal /m", want: []string{"/verbosity:minimal", "/m"}},
+		{s: "  --labels=After \t --workers=1  ", want: []string{"--labels=After", "--workers=1"}},
+		// the step.yml example
+		{
+			s:    `/p:MtouchExtraArgs="--linkskip=MyAssembly" /verbosity:minimal /m`,
+			want: []string{"/p:MtouchExtraArgs=--linkskip=MyAssembly", "/verbosity:minimal", "/m"},
+		},
+		{s: `/p:MtouchExtraArgs="--linkskip=A --linkskip=B"`, want: []string{"/p:MtouchExtraArgs=--linkskip=A --linkskip=B"}},
+		{s: `--where 'cat == UI && test != "Login"'`, want: []string{"--where", `cat == UI && test != "Login"`}},
+		{s: `"" ''`, want: []string{"", ""}},
+		{s: `a"b c"d`, want: []string{"ab cd"}},
+		{s: `path\ with\ spaces "quoted \"inner\""`, want: []string{"path with spaces", `quoted "inner"`}},
+		{s: `'single \ keeps backslash'`, want: []string{`single \ keeps backslash`}},
+		{s: `\"`, want: []string{`"`}},
+		{s: `"unterminated`, wantErr: true},
+		{s: `'unterminated`, wantErr: true},
+		{s: `trailing\`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := splitArgs(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("splitArgs(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
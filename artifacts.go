@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/junit"
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/nunitresult"
+	"github.com/bitrise-tools/go-steputils/tools"
+)
+
+// artifacts collects the outputs of the nunit runs,
+// so that runs finished earlier can be exported even if a later run fails.
+type artifacts struct {
+	deployDir string
+
+	resultLogs       []string
+	testResultsDir   string
+	testRunSummaries []testRunSummary
+}
+
+func newArtifacts(deployDir string) *artifacts {
+	return &artifacts{deployDir: deployDir}
+}
+
+// testRunID identifies a nunit run of a test project against a project,
+// simulatorName is only set if the tests run on multiple simulators.
+type testRunID struct {
+	testProjectName string
+	projectName     string
+	simulatorName   string
+}
+
+// fileName returns a file name friendly identifier of the test run.
+func (id testRunID) fileName() string {
+	name := id.testProjectName + "_" + id.projectName
+	if id.simulatorName != "" {
+		name += "_" + id.simulatorName
+	}
+
+	return sanitizeFileName(name)
+}
+
+// sanitizeFileName replaces every character, which is not safe in a file name, with _.
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// String ...
+func (id testRunID) String() string {
+	name := fmt.Sprintf("%s - %s", id.testProjectName, id.projectName)
+	if id.simulatorName != "" {
+		name += fmt.Sprintf(" (%s)", id.simulatorName)
+	}
+	return name
+}
+
+// resultLogPth returns a result log path unique to the given test run,
+// so that subsequent nunit runs do not overwrite each other's result.
+func (a *artifacts) resultLogPth(id testRunID) string {
+	return filepath.Join(a.deployDir, id.fileName()+"_TestResult.xml")
+}
+
+func (a *artifacts) retryResultLogPth(id testRunID, attempt int) string {
+	return filepath.Join(a.deployDir, fmt.Sprintf("%s_retry_%d_TestResult.xml", id.fileName(), attempt))
+}
+
+func (a *artifacts) mergedResultLogPth(id testRunID) string {
+	return filepath.Join(a.deployDir, id.fileName()+"_merged_TestResult.xml")
+}
+
+func (a *artifacts) outputLogPth(id testRunID) string {
+	return filepath.Join(a.deployDir, id.fileName()+"_output.log")
+}
+
+func (a *artifacts) diagnosticsZipPth(id testRunID) string {
+	return filepath.Join(a.deployDir, id.fileName()+"_diagnostics.zip")
+}
+
+func (a *artifacts) recordingPth(id testRunID) string {
+	return filepath.Join(a.deployDir, id.fileName()+"_recording.mp4")
+}
+
+func (a *artifacts) screenshotsDir(id testRunID) string {
+	return filepath.Join(a.deployDir, id.fileName()+"_screenshots")
+}
+
+func (a *artifacts) attachmentsDir(id testRunID) string {
+	return filepath.Join(a.deployDir, id.fileName()+"_attachments")
+}
+
+func (a *artifacts) addResultLog(resultLog string) {
+	a.resultLogs = append(a.resultLogs, resultLog)
+}
+
+func (a *artifacts) addTestRunSummary(summary testRunSummary) {
+	a.testRunSummaries = append(a.testRunSummaries, summary)
+}
+
+// addJUnitResult converts the nunit result into junit xml and writes it into the test results dir,
+// next to a test-info.json describing the test.
+func (a *artifacts) addJUnitResult(id testRunID, testRun nunitresult.TestRun) error {
+	testResultsDir := filepath.Join(a.deployDir, "test-results")
+	resultDir := filepath.Join(testResultsDir, id.fileName())
+	if err := pathutil.EnsureDirExist(resultDir); err != nil {
+		return fmt.Errorf("Failed to create dir (%s), error: %s", resultDir, err)
+	}
+
+	testName := id.String()
+
+	junitResult := junit.ConvertNunitResult(testName, testRun)
+	if err := junitResult.WriteToFile(filepath.Join(resultDir, "TEST-junit.xml")); err != nil {
+		return err
+	}
+
+	testInfo, err := json.Marshal(map[string]string{"test-name": testName})
+	if err != nil {
+		return fmt.Errorf("Failed to marshal test info, error: %s", err)
+	}
+
+	testInfoPth := filepath.Join(resultDir, "test-info.json")
+	if err := fileutil.WriteBytesToFile(testInfoPth, testInfo); err != nil {
+		return fmt.Errorf("Failed to write test info to (%s), error: %s", testInfoPth, err)
+	}
+
+	a.testResultsDir = testResultsDir
+
+	return nil
+}
+
+// mergeResultLogs combines the given nunit result logs into a single NUnit 3 result document (one <test-run>),
+// result logs which can not be parsed are skipped.
+func mergeResultLogs(resultLogs []string) (string, error) {
+	testRuns := []nunitresult.TestRun{}
+	for _, resultLog := range resultLogs {
+		testRun, err := nunitresult.Parse([]byte(resultLog))
+		if err != nil {
+			log.Warnf("Failed to parse test result, skipping it from the merged result, error: %s", err)
+			continue
+		}
+		testRuns = append(testRuns, testRun)
+	}
+
+	content, err := xml.MarshalIndent(nunitresult.Combine(testRuns...), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to marshal merged test result, error: %s", err)
+	}
+	return xml.Header + string(content), nil
+}
+
+// export writes the merged result logs into the deploy dir and exports the collected outputs.
+func (a *artifacts) export() {
+	if len(a.resultLogs) > 0 {
+		if mergedResultLog, err := mergeResultLogs(a.resultLogs); err != nil {
+			log.Warnf("%s", err)
+		} else {
+			mergedResultLogPth := filepath.Join(a.deployDir, "TestResult.xml")
+			if err := fileutil.WriteStringToFile(mergedResultLogPth, mergedResultLog); err != nil {
+				log.Warnf("Failed to write merged test result to (%s), error: %s", mergedResultLogPth, err)
+			} else {
+				log.Printf("merged test result: %s", mergedResultLogPth)
+			}
+
+			if err := tools.ExportEnvironmentWithEnvman("BITRISE_XAMARIN_TEST_FULL_RESULTS_TEXT", mergedResultLog); err != nil {
+				log.Warnf("Failed to export environment: %s, error: %s", "BITRISE_XAMARIN_TEST_FULL_RESULTS_TEXT", err)
+			}
+		}
+	}
+
+	if len(a.testRunSummaries) > 0 {
+		summaryPth := filepath.Join(a.deployDir, "test_summary.json")
+		if err := newTestSummary(a.testRunSummaries).writeToFile(summaryPth); err != nil {
+			log.Warnf("Failed to write test summary, error: %s", err)
+		} else {
+			log.Printf("test summary: %s", summaryPth)
+
+			if err := tools.ExportEnvironmentWithEnvman("BITRISE_XAMARIN_TEST_SUMMARY_PATH", summaryPth); err != nil {
+				log.Warnf("Failed to export environment: %s, error: %s", "BITRISE_XAMARIN_TEST_SUMMARY_PATH", err)
+			}
+		}
+	}
+
+	if len(a.testRunSummaries) > 0 {
+		flakyTestsPth := filepath.Join(a.deployDir, "flaky_tests.json")
+		if err := writeFlakyTests(a.testRunSummaries, flakyTestsPth); err != nil {
+			log.Warnf("Failed to write flaky tests, error: %s", err)
+		} else {
+			log.Printf("flaky tests: %s", flakyTestsPth)
+		}
+	}
+
+	if a.testResultsDir != "" {
+		if err := tools.ExportEnvironmentWithEnvman("BITRISE_XAMARIN_TEST_JUNIT_RESULTS_DIR", a.testResultsDir); err != nil {
+			log.Warnf("Failed to export environment: %s, error: %s", "BITRISE_XAMARIN_TEST_JUNIT_RESULTS_DIR", err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/nunitresult"
+)
+
+const passedResultLog = "\ufeff" + `<?xml version="1.0" encoding="utf-8" standalone="no"?>
+<test-run id="2" testcasecount="1" result="Passed" total="1" passed="1" failed="0" inconclusive="0" skipped="0" asserts="1" start-time="2018-05-14 10:00:00Z" end-time="2018-05-14 10:00:10Z" duration="10">
+  <command-line><![CDATA[nunit3-console.exe First.UITests.dll]]></command-line>
+  <test-suite type="Assembly" name="First.UITests.dll" fullname="First.UITests.dll" testcasecount="1" result="Passed" total="1" passed="1" failed="0" inconclusive="0" skipped="0" asserts="1" duration="10">
+    <test-case name="Passes" fullname="First.Tests.Passes" result="Passed" duration="10" asserts="1" />
+  </test-suite>
+</test-run>`
+
+const failedResultLog = `<?xml version="1.0" encoding="utf-8" standalone="no"?>
+<test-run id="2" testcasecount="2" result="Failed" total="2" passed="1" failed="1" inconclusive="0" skipped="0" asserts="2" start-time="2018-05-14 09:59:00Z" end-time="2018-05-14 10:01:00Z" duration="5.5">
+  <test-suite type="Assembly" name="Second.UITests.dll" fullname="Second.UITests.dll" testcasecount="2" result="Failed" site="Child" total="2" passed="1" failed="1" inconclusive="0" skipped="0" asserts="2" duration="5.5">
+    <test-case name="Passes" fullname="Second.Tests.Passes" result="Passed" duration="2" asserts="1" />
+    <test-case name="Fails" fullname="Second.Tests.Fails" result="Failed" duration="3.5" asserts="1">
+      <failure>
+        <message><![CDATA[Expected: True]]></message>
+      </failure>
+    </test-case>
+  </test-suite>
+</test-run>`
+
+func TestMergeResultLogs(t *testing.T) {
+	merged, err := mergeResultLogs([]string{passedResultLog, "not a result log", failedResultLog})
+	if err != nil {
+		t.Fatalf("mergeResultLogs() error: %s", err)
+	}
+
+	testRun, err := nunitresult.Parse([]byte(merged))
+	if err != nil {
+		t.Fatalf("merged result log is not a NUnit 3 result: %s\n%s", err, merged)
+	}
+
+	if testRun.Result != nunitresult.ResultFailed {
+		t.Errorf("Result = %s, want %s", testRun.Result, nunitresult.ResultFailed)
+	}
+	if testRun.TestCaseCount != 3 || testRun.Total != 3 || testRun.Passed != 2 || testRun.Failed != 1 || testRun.Asserts != 3 {
+		t.Errorf("counts = %d/%d/%d/%d/%d, want 3/3/2/1/3", testRun.TestCaseCount, testRun.Total, testRun.Passed, testRun.Failed, testRun.Asserts)
+	}
+	if testRun.Duration != 15.5 {
+		t.Errorf("Duration = %v, want 15.5", testRun.Duration)
+	}
+	if testRun.StartTime != "2018-05-14 09:59:00Z" || testRun.EndTime != "2018-05-14 10:01:00Z" {
+		t.Errorf("StartTime, EndTime = %s, %s", testRun.StartTime, testRun.EndTime)
+	}
+	if len(testRun.TestSuites) != 2 || testRun.TestSuites[0].Name != "First.UITests.dll" || testRun.TestSuites[1].Name != "Second.UITests.dll" {
+		t.Errorf("TestSuites = %+v", testRun.TestSuites)
+	}
+	if got := len(testRun.FailedTestCases()); got != 1 {
+		t.Errorf("FailedTestCases() = %d, want 1", got)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/nunitresult"
+)
+
+// otherAttachmentsDir is the folder of the screenshots, which could not be assigned to a test case.
+const otherAttachmentsDir = "_other"
+
+var screenshotExts = []string{".png", ".jpg", ".jpeg"}
+
+func isScreenshot(pth string) bool {
+	ext := strings.ToLower(filepath.Ext(pth))
+	for _, screenshotExt := range screenshotExts {
+		if ext == screenshotExt {
+			return true
+		}
+	}
+	return false
+}
+
+// newScreenshots returns the screenshots (app.Screenshot()) created in the dirs since startTime, with their modification time.
+func newScreenshots(dirs []string, startTime time.Time) map[string]time.Time {
+	screenshots := map[string]time.Time{}
+
+	for _, dir := range dirs {
+		fileInfos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			log.Warnf("Failed to list screenshots in (%s), error: %s", dir, err)
+			continue
+		}
+
+		for _, info := range fileInfos {
+			pth := filepath.Join(dir, info.Name())
+			if info.IsDir() || !isScreenshot(pth) || info.ModTime().Before(startTime) {
+				continue
+			}
+			screenshots[pth] = info.ModTime()
+		}
+	}
+
+	return screenshots
+}
+
+func sortedScreenshots(screenshots map[string]time.Time) []string {
+	pths := []string{}
+	for pth := range screenshots {
+		pths = append(pths, pth)
+	}
+	sort.Strings(pths)
+	return pths
+}
+
+// testCaseAt returns the test case, which was running at t.
+func testCaseAt(testCases []nunitresult.TestCase, t time.Time) (nunitresult.TestCase, bool) {
+	for _, testCase := range testCases {
+		startTime, err := nunitresult.ParseTime(testCase.StartTime)
+		if err != nil {
+			continue
+		}
+		endTime, err := nunitresult.ParseTime(testCase.EndTime)
+		if err != nil {
+			continue
+		}
+
+		// the result times are in seconds precision
+		if !t.Before(startTime.Add(-time.Second)) && !t.After(endTime.Add(time.Second)) {
+			return testCase, true
+		}
+	}
+	return nunitresult.TestCase{}, false
+}
+
+// collectAttachments copies the attachments referenced in the test result and the screenshots created during the test run
+// into a per test case folder under attachmentsDir.
+// It returns the copied files per test case full name.
+func collectAttachments(testRun *nunitresult.TestRun, screenshotDirs []string, startTime time.Time, attachmentsDir string) map[string][]string {
+	collected := map[string][]string{}
+	copied := map[string]bool{}
+
+	collect := func(testName, pth string) {
+		dir := filepath.Join(attachmentsDir, sanitizeFileName(testName))
+		if err := pathutil.EnsureDirExist(dir); err != nil {
+			log.Warnf("Failed to create attachments dir (%s), error: %s", dir, err)
+			return
+		}
+
+		dst := filepath.Join(dir, filepath.Base(pth))
+		if err := command.CopyFile(pth, dst); err != nil {
+			log.Warnf("Failed to copy attachment (%s), error: %s", pth, err)
+			return
+		}
+
+		copied[pth] = true
+		collected[testName] = append(collected[testName], dst)
+	}
+
+	testCases := []nunitresult.TestCase{}
+	if testRun != nil {
+		testCases = testRun.TestCases()
+	}
+
+	for _, testCase := range testCases {
+		if testCase.Attachments == nil {
+			continue
+		}
+
+		for _, attachment := range testCase.Attachments.Items {
+			pth := attachment.FilePath
+			if exist, err := pathutil.IsPathExists(pth); err != nil || !exist {
+				log.Warnf("Attachment (%s) of %s not found", pth, testCase.FullName)
+				continue
+			}
+
+			collect(testCase.FullName, pth)
+		}
+	}
+
+	screenshots := newScreenshots(screenshotDirs, startTime)
+	for _, pth := range sortedScreenshots(screenshots) {
+		if copied[pth] {
+			continue
+		}
+
+		if testCase, ok := testCaseAt(testCases, screenshots[pth]); ok {
+			collect(testCase.FullName, pth)
+		} else {
+			collect(otherAttachmentsDir, pth)
+		}
+	}
+
+	if len(collected) > 0 {
+		log.Printf("attachments: %s", attachmentsDir)
+	}
+
+	return collected
+}
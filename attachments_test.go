@@ -0,0 +1,145 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/nunitresult"
+)
+
+func TestTestCaseAt(t *testing.T) {
+	testCases := []nunitresult.TestCase{
+		{FullName: "Tests.Invalid", StartTime: "now", EndTime: "later"},
+		{FullName: "Tests.Login", StartTime: "2019-03-01 10:00:00Z", EndTime: "2019-03-01 10:00:10Z"},
+		{FullName: "Tests.Logout", StartTime: "2019-03-01T10:00:20Z", EndTime: "2019-03-01T10:00:30Z"},
+	}
+
+	at := func(value string) time.Time {
+		tm, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			t.Fatalf("Failed to parse time (%s), error: %s", value, err)
+		}
+		return tm
+	}
+
+	tests := []struct {
+		name   string
+		t      time.Time
+		want   string
+		wantOk bool
+	}{
+		{name: "during the test", t: at("2019-03-01T10:00:05Z"), want: "Tests.Login", wantOk: true},
+		{name: "in the second before the start", t: at("2019-03-01T09:59:59.5Z"), want: "Tests.Login", wantOk: true},
+		{name: "in the second after the end", t: at("2019-03-01T10:00:30.9Z"), want: "Tests.Logout", wantOk: true},
+		{name: "before the run", t: at("2019-03-01T09:59:58Z")},
+		{name: "between the tests", t: at("2019-03-01T10:00:15Z")},
+		{name: "after the run", t: at("2019-03-01T10:00:32Z")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := testCaseAt(testCases, tt.t)
+			if ok != tt.wantOk || got.FullName != tt.want {
+				t.Errorf("testCaseAt() = %s, %v, want %s, %v", got.FullName, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestCollectAttachments(t *testing.T) {
+	// command.CopyFile copies with rsync
+	if _, err := exec.LookPath("rsync"); err != nil {
+		t.Skip("rsync not found")
+	}
+
+	tmpDir, err := ioutil.TempDir("", "attachments")
+	if err != nil {
+		t.Fatalf("Failed to create tmp dir, error: %s", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove tmp dir, error: %s", err)
+		}
+	}()
+
+	startTime := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+	screenshotDir := filepath.Join(tmpDir, "screenshots")
+	writeFile := func(pth string, modTime time.Time) string {
+		pth = writeTestFile(t, tmpDir, pth, pth)
+		if err := os.Chtimes(pth, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set modification time of (%s), error: %s", pth, err)
+		}
+		return pth
+	}
+
+	logPth := writeFile("logs/login.log", startTime.Add(5*time.Second))
+	// referenced in the result and created in the screenshot dir, collected once
+	referencedPth := writeFile("screenshots/login_failed.png", startTime.Add(8*time.Second))
+	writeFile("screenshots/screenshot-1.png", startTime.Add(3*time.Second))
+	writeFile("screenshots/screenshot-2.jpg", startTime.Add(25*time.Second))
+	writeFile("screenshots/screenshot-3.png", startTime.Add(15*time.Second))
+	// older than the test run
+	writeFile("screenshots/screenshot-0.png", startTime.Add(-time.Minute))
+	// not a screenshot
+	writeFile("screenshots/TestResult.xml", startTime.Add(5*time.Second))
+
+	testRun := &nunitresult.TestRun{
+		TestSuites: []nunitresult.TestSuite{{
+			Name: "Tests",
+			TestCases: []nunitresult.TestCase{
+				{
+					FullName:  "Tests.Login",
+					StartTime: "2019-03-01 10:00:00Z",
+					EndTime:   "2019-03-01 10:00:10Z",
+					Attachments: &nunitresult.Attachments{Items: []nunitresult.Attachment{
+						{FilePath: logPth},
+						{FilePath: referencedPth},
+						{FilePath: filepath.Join(tmpDir, "missing.png")},
+					}},
+				},
+				{
+					FullName:  "Tests.Logout",
+					StartTime: "2019-03-01 10:00:20Z",
+					EndTime:   "2019-03-01 10:00:30Z",
+				},
+			},
+		}},
+	}
+
+	attachmentsDir := filepath.Join(tmpDir, "deploy", "Tests_App_attachments")
+	got := collectAttachments(testRun, []string{screenshotDir, filepath.Join(tmpDir, "missing")}, startTime, attachmentsDir)
+
+	want := map[string][]string{
+		"Tests.Login": {
+			filepath.Join(attachmentsDir, "Tests.Login", "login.log"),
+			filepath.Join(attachmentsDir, "Tests.Login", "login_failed.png"),
+			filepath.Join(attachmentsDir, "Tests.Login", "screenshot-1.png"),
+		},
+		"Tests.Logout": {
+			filepath.Join(attachmentsDir, "Tests.Logout", "screenshot-2.jpg"),
+		},
+		otherAttachmentsDir: {
+			filepath.Join(attachmentsDir, otherAttachmentsDir, "screenshot-3.png"),
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("collectAttachments() =\n%v\nwant\n%v", got, want)
+	}
+
+	for _, pths := range got {
+		for _, pth := range pths {
+			if _, err := os.Stat(pth); err != nil {
+				t.Errorf("attachment (%s) not copied, error: %s", pth, err)
+			}
+		}
+	}
+
+	if got := collectAttachments(nil, []string{screenshotDir}, startTime.Add(time.Hour), attachmentsDir); len(got) != 0 {
+		t.Errorf("collectAttachments() = %v, want nothing without new screenshots", got)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/simctl"
+)
+
+// bundleIDOfApp reads the CFBundleIdentifier of the given .app.
+func bundleIDOfApp(appPth string) (string, error) {
+	infoPlistPth := filepath.Join(appPth, "Info.plist")
+	cmd := command.New("/usr/libexec/PlistBuddy", "-c", "Print :CFBundleIdentifier", infoPlistPth)
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s failed, output: %s, error: %s", cmd.PrintableCommandArgs(), out, err)
+	}
+	return out, nil
+}
+
+func diagnosticReportsDirs() []string {
+	return []string{
+		filepath.Join(pathutil.UserHomeDir(), "Library", "Logs", "DiagnosticReports"),
+		filepath.Join("/Library", "Logs", "DiagnosticReports"),
+	}
+}
+
+// diagnosticsCapture collects the system log of a simulator and the crash reports of the app during a test run.
+type diagnosticsCapture struct {
+	udid      string
+	bundleID  string
+	startTime time.Time
+
+	dir       string
+	logFile   *os.File
+	logStream *simctl.BackgroundProcess
+}
+
+// startDiagnosticsCapture starts streaming the simulator's system log into a temporary dir.
+func startDiagnosticsCapture(udid, bundleID string) (*diagnosticsCapture, error) {
+	dir, err := pathutil.NormalizedOSTempDirPath("simulator-diagnostics")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create tmp dir for the diagnostics, error: %s", err)
+	}
+
+	logPth := filepath.Join(dir, "system.log")
+	logFile, err := os.Create(logPth)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create system log (%s), error: %s", logPth, err)
+	}
+
+	logStream, err := simctl.StreamLog(udid, logFile)
+	if err != nil {
+		if closeErr := logFile.Close(); closeErr != nil {
+			log.Warnf("Failed to close system log (%s), error: %s", logPth, closeErr)
+		}
+		return nil, fmt.Errorf("Failed to stream simulator log, error: %s", err)
+	}
+
+	return &diagnosticsCapture{
+		udid:      udid,
+		bundleID:  bundleID,
+		startTime: time.Now(),
+		dir:       dir,
+		logFile:   logFile,
+		logStream: logStream,
+	}, nil
+}
+
+// isCrashReportOfApp reports if the crash report was created during the capture and belongs to the app.
+// Every new crash report is collected if the bundle id of the app is unknown.
+func (capture *diagnosticsCapture) isCrashReportOfApp(pth string, info os.FileInfo) bool {
+	ext := filepath.Ext(pth)
+	if ext != ".crash" && ext != ".ips" {
+		return false
+	}
+	if info.ModTime().Before(capture.startTime) {
+		return false
+	}
+	if capture.bundleID == "" {
+		return true
+	}
+
+	content, err := fileutil.ReadStringFromFile(pth)
+	if err != nil {
+		log.Warnf("Failed to read crash report (%s), error: %s", pth, err)
+		return false
+	}
+	return strings.Contains(content, capture.bundleID)
+}
+
+func (capture *diagnosticsCapture) collectCrashReports() error {
+	crashReportsDir := filepath.Join(capture.dir, "CrashReports")
+	if err := pathutil.EnsureDirExist(crashReportsDir); err != nil {
+		return err
+	}
+
+	for _, dir := range diagnosticReportsDirs() {
+		if exist, err := pathutil.IsDirExists(dir); err != nil {
+			return err
+		} else if !exist {
+			continue
+		}
+
+		fileInfos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return fmt.Errorf("Failed to list crash reports in (%s), error: %s", dir, err)
+		}
+
+		for _, info := range fileInfos {
+			pth := filepath.Join(dir, info.Name())
+			if info.IsDir() || !capture.isCrashReportOfApp(pth, info) {
+				continue
+			}
+
+			log.Printf("crash report: %s", pth)
+			if err := command.CopyFile(pth, filepath.Join(crashReportsDir, info.Name())); err != nil {
+				return fmt.Errorf("Failed to copy crash report (%s), error: %s", pth, err)
+			}
+		}
+	}
+	return nil
+}
+
+// finish stops the log stream, collects the crash reports and zips everything into zipPth.
+func (capture *diagnosticsCapture) finish(zipPth string) error {
+	if err := capture.logStream.Stop(); err != nil {
+		log.Warnf("Failed to stop simulator log stream, error: %s", err)
+	}
+	if err := capture.logFile.Close(); err != nil {
+		log.Warnf("Failed to close system log, error: %s", err)
+	}
+
+	if err := capture.collectCrashReports(); err != nil {
+		log.Warnf("Failed to collect crash reports, error: %s", err)
+	}
+
+	return zipDir(capture.dir, zipPth)
+}
+
+// zipDir zips the content of the dir into zipPth.
+func zipDir(dir, zipPth string) error {
+	zipFile, err := os.Create(zipPth)
+	if err != nil {
+		return fmt.Errorf("Failed to create zip (%s), error: %s", zipPth, err)
+	}
+	defer func() {
+		if err := zipFile.Close(); err != nil {
+			log.Warnf("Failed to close zip (%s), error: %s", zipPth, err)
+		}
+	}()
+
+	zipWriter := zip.NewWriter(zipFile)
+	if err := filepath.Walk(dir, func(pth string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relPth, err := filepath.Rel(dir, pth)
+		if err != nil {
+			return err
+		}
+
+		writer, err := zipWriter.Create(filepath.ToSlash(relPth))
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(pth)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := file.Close(); err != nil {
+				log.Warnf("Failed to close (%s), error: %s", pth, err)
+			}
+		}()
+
+		_, err = io.Copy(writer, file)
+		return err
+	}); err != nil {
+		return fmt.Errorf("Failed to zip (%s), error: %s", dir, err)
+	}
+
+	return zipWriter.Close()
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// nameFilter selects names by include and exclude patterns (exact names or globs, like *.UITests).
+// No include pattern means every name is included.
+type nameFilter struct {
+	include []string
+	exclude []string
+}
+
+func newNameFilter(include, exclude string) nameFilter {
+	return nameFilter{
+		include: splitCommaSeparatedList(include),
+		exclude: splitCommaSeparatedList(exclude),
+	}
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// validatePatterns checks the syntax of the patterns.
+func (filter nameFilter) validatePatterns() error {
+	for _, pattern := range append(append([]string{}, filter.include...), filter.exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern (%s), error: %s", pattern, err)
+		}
+	}
+	return nil
+}
+
+// checkUnmatched returns an error if any of the patterns does not match any of the available names.
+func (filter nameFilter) checkUnmatched(names []string) error {
+	unmatched := []string{}
+	for _, pattern := range append(append([]string{}, filter.include...), filter.exclude...) {
+		found := false
+		for _, name := range names {
+			if matchesAny([]string{pattern}, name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unmatched = append(unmatched, pattern)
+		}
+	}
+
+	if len(unmatched) > 0 {
+		sorted := []string{}
+		seen := map[string]bool{}
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				sorted = append(sorted, name)
+			}
+		}
+		sort.Strings(sorted)
+		return fmt.Errorf("filter(s) (%s) do not match any of the available names: %s", strings.Join(unmatched, ", "), strings.Join(sorted, ", "))
+	}
+	return nil
+}
+
+// active returns true if the filter has any pattern.
+func (filter nameFilter) active() bool {
+	return len(filter.include) > 0 || len(filter.exclude) > 0
+}
+
+func (filter nameFilter) matches(name string) bool {
+	if len(filter.include) > 0 && !matchesAny(filter.include, name) {
+		return false
+	}
+	return !matchesAny(filter.exclude, name)
+}
+
+// filterTestPairs returns the test pairs selected by the test project and app project filters.
+func filterTestPairs(pairs []testPair, testProjectFilter, projectFilter nameFilter) ([]testPair, error) {
+	testProjectNames := []string{}
+	projectNames := []string{}
+	testProjectSeen := map[string]bool{}
+	projectSeen := map[string]bool{}
+	for _, pair := range pairs {
+		if !testProjectSeen[pair.testProjectName] {
+			testProjectSeen[pair.testProjectName] = true
+			testProjectNames = append(testProjectNames, pair.testProjectName)
+		}
+		if !projectSeen[pair.projectName] {
+			projectSeen[pair.projectName] = true
+			projectNames = append(projectNames, pair.projectName)
+		}
+	}
+
+	if err := testProjectFilter.checkUnmatched(testProjectNames); err != nil {
+		return nil, fmt.Errorf("Test project %s", err)
+	}
+	if err := projectFilter.checkUnmatched(projectNames); err != nil {
+		return nil, fmt.Errorf("App project %s", err)
+	}
+
+	filtered := []testPair{}
+	for _, pair := range pairs {
+		if testProjectFilter.matches(pair.testProjectName) && projectFilter.matches(pair.projectName) {
+			filtered = append(filtered, pair)
+		}
+	}
+
+	if len(filtered) == 0 {
+		return nil, fmt.Errorf("No test project - app project pair left after applying the filters")
+	}
+	return filtered, nil
+}
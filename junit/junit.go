@@ -0,0 +1,157 @@
+package junit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/nunitresult"
+)
+
+// TestSuites is the root element of the junit result file.
+type TestSuites struct {
+	XMLName  xml.Name `xml:"testsuites"`
+	Name     string   `xml:"name,attr,omitempty"`
+	Tests    int      `xml:"tests,attr"`
+	Failures int      `xml:"failures,attr"`
+	Errors   int      `xml:"errors,attr"`
+	Skipped  int      `xml:"skipped,attr"`
+	Time     float64  `xml:"time,attr"`
+
+	TestSuites []TestSuite `xml:"testsuite"`
+}
+
+// TestSuite ...
+type TestSuite struct {
+	Name      string  `xml:"name,attr"`
+	Tests     int     `xml:"tests,attr"`
+	Failures  int     `xml:"failures,attr"`
+	Errors    int     `xml:"errors,attr"`
+	Skipped   int     `xml:"skipped,attr"`
+	Time      float64 `xml:"time,attr"`
+	Timestamp string  `xml:"timestamp,attr,omitempty"`
+
+	TestCases []TestCase `xml:"testcase"`
+}
+
+// TestCase ...
+type TestCase struct {
+	Name      string  `xml:"name,attr"`
+	ClassName string  `xml:"classname,attr"`
+	Time      float64 `xml:"time,attr"`
+
+	Failure   *Failure `xml:"failure,omitempty"`
+	Skipped   *Skipped `xml:"skipped,omitempty"`
+	SystemOut string   `xml:"system-out,omitempty"`
+}
+
+// Failure ...
+type Failure struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Value   string `xml:",chardata"`
+}
+
+// Skipped ...
+type Skipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// ConvertNunitResult creates a junit testsuite from every nunit test suite which directly contains test cases.
+func ConvertNunitResult(name string, testRun nunitresult.TestRun) TestSuites {
+	testSuites := TestSuites{Name: name}
+	for _, nunitTestSuite := range testRun.TestSuites {
+		testSuites.TestSuites = append(testSuites.TestSuites, convertNunitTestSuite(nunitTestSuite)...)
+	}
+
+	for _, testSuite := range testSuites.TestSuites {
+		testSuites.Tests += testSuite.Tests
+		testSuites.Failures += testSuite.Failures
+		testSuites.Errors += testSuite.Errors
+		testSuites.Skipped += testSuite.Skipped
+		testSuites.Time += testSuite.Time
+	}
+
+	return testSuites
+}
+
+func convertNunitTestSuite(nunitTestSuite nunitresult.TestSuite) []TestSuite {
+	testSuites := []TestSuite{}
+
+	if len(nunitTestSuite.TestCases) > 0 {
+		testSuite := TestSuite{
+			Name:      nunitTestSuite.FullName,
+			Timestamp: nunitTestSuite.StartTime,
+		}
+
+		for _, nunitTestCase := range nunitTestSuite.TestCases {
+			testCase := convertNunitTestCase(nunitTestCase, nunitTestSuite.FullName)
+
+			testSuite.Tests++
+			testSuite.Time += testCase.Time
+			if testCase.Failure != nil {
+				testSuite.Failures++
+			}
+			if testCase.Skipped != nil {
+				testSuite.Skipped++
+			}
+
+			testSuite.TestCases = append(testSuite.TestCases, testCase)
+		}
+
+		testSuites = append(testSuites, testSuite)
+	}
+
+	for _, childSuite := range nunitTestSuite.TestSuites {
+		testSuites = append(testSuites, convertNunitTestSuite(childSuite)...)
+	}
+
+	return testSuites
+}
+
+func convertNunitTestCase(nunitTestCase nunitresult.TestCase, suiteFullName string) TestCase {
+	className := nunitTestCase.ClassName
+	if className == "" {
+		className = suiteFullName
+	}
+
+	testCase := TestCase{
+		Name:      nunitTestCase.Name,
+		ClassName: className,
+		Time:      nunitTestCase.Duration,
+		SystemOut: strings.TrimSpace(nunitTestCase.Output),
+	}
+
+	switch nunitTestCase.Result {
+	case nunitresult.ResultFailed:
+		failure := Failure{Type: nunitTestCase.Label}
+		if nunitTestCase.Failure != nil {
+			failure.Message = strings.TrimSpace(nunitTestCase.Failure.Message)
+			failure.Value = strings.TrimSpace(nunitTestCase.Failure.StackTrace)
+		}
+		testCase.Failure = &failure
+	case nunitresult.ResultSkipped, nunitresult.ResultInconclusive:
+		skipped := Skipped{}
+		if nunitTestCase.Reason != nil {
+			skipped.Message = strings.TrimSpace(nunitTestCase.Reason.Message)
+		}
+		testCase.Skipped = &skipped
+	}
+
+	return testCase
+}
+
+// WriteToFile ...
+func (testSuites TestSuites) WriteToFile(pth string) error {
+	content, err := xml.MarshalIndent(testSuites, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to marshal junit result, error: %s", err)
+	}
+
+	if err := fileutil.WriteStringToFile(pth, xml.Header+string(content)); err != nil {
+		return fmt.Errorf("Failed to write junit result to (%s), error: %s", pth, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package junit
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/nunitresult"
+)
+
+func TestConvertNunitResult(t *testing.T) {
+	testRun, err := nunitresult.ParseFile("../nunitresult/testdata/TestResult.xml")
+	if err != nil {
+		t.Fatalf("ParseFile() error: %s", err)
+	}
+
+	testSuites := ConvertNunitResult("CreditCardValidator.iOS.UITests", testRun)
+
+	if testSuites.Name != "CreditCardValidator.iOS.UITests" || testSuites.Tests != 4 || testSuites.Failures != 1 || testSuites.Skipped != 2 || testSuites.Errors != 0 {
+		t.Errorf("testsuites = %+v", testSuites)
+	}
+	if testSuites.Time != 89.5 {
+		t.Errorf("testsuites Time = %v, want 89.5", testSuites.Time)
+	}
+
+	// only the fixture contains test cases, the assembly and namespace suites are flattened
+	if len(testSuites.TestSuites) != 1 {
+		t.Fatalf("testsuites = %d test suites, want 1", len(testSuites.TestSuites))
+	}
+	testSuite := testSuites.TestSuites[0]
+	if testSuite.Name != "CreditCardValidator.ValidateCreditCardTests" || testSuite.Timestamp != "2018-05-14 10:00:01Z" || testSuite.Tests != 4 {
+		t.Errorf("testsuite = %+v", testSuite)
+	}
+
+	passed, failed, ignored, inconclusive := testSuite.TestCases[0], testSuite.TestCases[1], testSuite.TestCases[2], testSuite.TestCases[3]
+
+	if passed.Failure != nil || passed.Skipped != nil || passed.ClassName != "CreditCardValidator.ValidateCreditCardTests" || passed.Time != 29.5 {
+		t.Errorf("passed test case = %+v", passed)
+	}
+
+	if failed.Failure == nil {
+		t.Fatalf("failed test case has no failure")
+	}
+	if failed.Failure.Type != "Error" || failed.Failure.Message != "Timed out waiting for element..." || failed.Failure.Value != "at Xamarin.UITest.Shared.WaitForHelper.WaitForAny" {
+		t.Errorf("failure = %+v", failed.Failure)
+	}
+	if failed.SystemOut != "Tapping on CardNumber" {
+		t.Errorf("SystemOut = %s", failed.SystemOut)
+	}
+
+	if ignored.Skipped == nil || ignored.Skipped.Message != "Flaky on iOS 11" {
+		t.Errorf("ignored test case = %+v", ignored)
+	}
+	if inconclusive.Skipped == nil || inconclusive.Skipped.Message != "No card reader" {
+		t.Errorf("inconclusive test case = %+v", inconclusive)
+	}
+}
+
+func TestConvertNunitResultClassName(t *testing.T) {
+	testRun := nunitresult.TestRun{
+		TestSuites: []nunitresult.TestSuite{
+			{
+				FullName: "Tests.Fixture",
+				TestCases: []nunitresult.TestCase{
+					{Name: "WithoutClassName", Result: nunitresult.ResultFailed},
+				},
+			},
+		},
+	}
+
+	testSuites := ConvertNunitResult("Tests", testRun)
+
+	testCase := testSuites.TestSuites[0].TestCases[0]
+	if testCase.ClassName != "Tests.Fixture" {
+		t.Errorf("ClassName = %s, want the suite full name", testCase.ClassName)
+	}
+	if testCase.Failure == nil || testCase.Failure.Message != "" {
+		t.Errorf("Failure = %+v, want an empty failure", testCase.Failure)
+	}
+
+	content, err := xml.Marshal(testSuites)
+	if err != nil {
+		t.Fatalf("Marshal() error: %s", err)
+	}
+	want := `<testsuites name="Tests" tests="1" failures="1" errors="0" skipped="0" time="0"><testsuite name="Tests.Fixture" tests="1" failures="1" errors="0" skipped="0" time="0"><testcase name="WithoutClassName" classname="Tests.Fixture" time="0"><failure></failure></testcase></testsuite></testsuites>`
+	if string(content) != want {
+		t.Errorf("Marshal() =\n%s\nwant\n%s", content, want)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/simctl"
+	"github.com/bitrise-tools/go-xcode/models"
+	"github.com/bitrise-tools/go-xcode/simulator"
+)
+
+// Simulator boot modes
+const (
+	bootModeNone         = "none"
+	bootModeSimctl       = "simctl"
+	bootModeSimulatorApp = "simulator_app"
+)
+
+// Simulator actions after the tests
+const (
+	afterTestKeep     = "keep"
+	afterTestShutdown = "shutdown"
+	afterTestErase    = "erase"
+)
+
+func xcodebuildVersion() (models.XcodebuildVersionModel, error) {
+	cmd := command.New("xcodebuild", "-version")
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return models.XcodebuildVersionModel{}, fmt.Errorf("%s failed, output: %s, error: %s", cmd.PrintableCommandArgs(), out, err)
+	}
+
+	// Xcode 10.1
+	// Build version 10B61
+	match := regexp.MustCompile(`Xcode (?P<version>([0-9]+)[0-9.]*)\s+Build version (?P<build>\S+)`).FindStringSubmatch(out)
+	if match == nil {
+		return models.XcodebuildVersionModel{}, fmt.Errorf("failed to parse xcodebuild version output: %s", out)
+	}
+
+	majorVersion, err := strconv.ParseInt(match[2], 10, 64)
+	if err != nil {
+		return models.XcodebuildVersionModel{}, fmt.Errorf("failed to parse xcode major version (%s), error: %s", match[2], err)
+	}
+
+	return models.XcodebuildVersionModel{
+		Version:      match[1],
+		BuildVersion: match[3],
+		MajorVersion: majorVersion,
+	}, nil
+}
+
+// bootSimulator boots the simulator according to the boot mode:
+// simctl boots it and waits until it is ready, simulator_app opens Simulator.app with the device.
+func bootSimulator(info simulator.InfoModel, bootMode string, timeout time.Duration) error {
+	switch bootMode {
+	case bootModeSimctl:
+		log.Printf("booting simulator: %s (%s)", info.Name, info.ID)
+		if err := simctl.Boot(info.ID); err != nil {
+			return fmt.Errorf("Failed to boot simulator, error: %s", err)
+		}
+
+		log.Printf("waiting for simulator to be ready, timeout: %s", timeout)
+		if err := simctl.WaitForBoot(info.ID, timeout); err != nil {
+			return fmt.Errorf("Failed to wait for simulator boot, error: %s", err)
+		}
+	case bootModeSimulatorApp:
+		xcodebuildVersion, err := xcodebuildVersion()
+		if err != nil {
+			return fmt.Errorf("Failed to get xcodebuild version, error: %s", err)
+		}
+
+		log.Printf("opening Simulator.app with simulator: %s (%s)", info.Name, info.ID)
+		if err := simulator.BootSimulator(info, xcodebuildVersion); err != nil {
+			return fmt.Errorf("Failed to boot simulator, error: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// teardownSimulator shuts down or erases the simulator after the tests.
+func teardownSimulator(info simulator.InfoModel, afterTest string) error {
+	switch afterTest {
+	case afterTestShutdown:
+		log.Printf("shutting down simulator: %s (%s)", info.Name, info.ID)
+		if err := simctl.Shutdown(info.ID); err != nil {
+			return fmt.Errorf("Failed to shut down simulator, error: %s", err)
+		}
+	case afterTestErase:
+		log.Printf("erasing simulator: %s (%s)", info.Name, info.ID)
+		if err := simctl.Erase(info.ID); err != nil {
+			return fmt.Errorf("Failed to erase simulator, error: %s", err)
+		}
+	}
+
+	return nil
+}
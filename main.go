@@ -1,52 +1,127 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/bitrise-io/go-utils/fileutil"
 	"github.com/bitrise-io/go-utils/log"
 	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/nunitresult"
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/simctl"
 	"github.com/bitrise-tools/go-steputils/input"
 	"github.com/bitrise-tools/go-steputils/tools"
 	"github.com/bitrise-tools/go-xamarin/builder"
 	"github.com/bitrise-tools/go-xamarin/constants"
+	xamarintools "github.com/bitrise-tools/go-xamarin/tools"
 	"github.com/bitrise-tools/go-xamarin/tools/buildtools"
 	"github.com/bitrise-tools/go-xamarin/tools/nunit"
-	"github.com/bitrise-tools/go-xcode/simulator"
-	"github.com/hashicorp/go-version"
 )
 
 // ConfigsModel ...
 type ConfigsModel struct {
-	SimulatorDevice    string
-	SimulatorOsVersion string
-	TestToRun          string
+	SimulatorDevice       string
+	SimulatorOsVersion    string
+	SimulatorDeviceMatrix string
+	CreateSimulator       string
+	SimulatorMatchPolicy  string
+	FallbackDevices       string
+	EraseSimulator        string
+	CollectDiagnostics    string
+	RecordSimulator       string
+	CollectAttachments    string
+	SimulatorBootMode     string
+	SimulatorBootTimeout  string
+	SimulatorAfterTest    string
+	TestToRun             string
+	TestWhere             string
+	IncludeCategories     string
+	ExcludeCategories     string
+	TestListPth           string
+	RetryFailedTests      string
+	TestTimeout           string
+	GlobalTimeout         string
+	QuarantineListPth     string
+	ShardIndex            string
+	ShardCount            string
+	ShardTimingPth        string
 
 	XamarinSolution      string
 	XamarinConfiguration string
 	XamarinPlatform      string
 
-	BuildTool string
-	DeployDir string
+	AppPth          string
+	TestAssemblyPth string
+
+	TestProjects        string
+	ExcludeTestProjects string
+	AppProjects         string
+	ExcludeAppProjects  string
+	TestOrder           string
+
+	BuildTool       string
+	BuildOptions    string
+	NunitOptions    string
+	MonoPth         string
+	MsbuildPth      string
+	XbuildPth       string
+	NunitConsolePth string
+	DeployDir       string
 }
 
 func createConfigsModelFromEnvs() ConfigsModel {
 	return ConfigsModel{
-		SimulatorDevice:    os.Getenv("simulator_device"),
-		SimulatorOsVersion: os.Getenv("simulator_os_version"),
-		TestToRun:          os.Getenv("test_to_run"),
+		SimulatorDevice:       os.Getenv("simulator_device"),
+		SimulatorOsVersion:    os.Getenv("simulator_os_version"),
+		SimulatorDeviceMatrix: os.Getenv("simulator_device_matrix"),
+		CreateSimulator:       os.Getenv("create_simulator"),
+		SimulatorMatchPolicy:  os.Getenv("simulator_match_policy"),
+		FallbackDevices:       os.Getenv("simulator_fallback_devices"),
+		EraseSimulator:        os.Getenv("erase_simulator"),
+		CollectDiagnostics:    os.Getenv("collect_simulator_diagnostics"),
+		RecordSimulator:       os.Getenv("record_simulator"),
+		CollectAttachments:    os.Getenv("collect_attachments"),
+		SimulatorBootMode:     os.Getenv("simulator_boot_mode"),
+		SimulatorBootTimeout:  os.Getenv("simulator_boot_timeout"),
+		SimulatorAfterTest:    os.Getenv("simulator_after_test"),
+		TestToRun:             os.Getenv("test_to_run"),
+		TestWhere:             os.Getenv("test_where"),
+		IncludeCategories:     os.Getenv("include_categories"),
+		ExcludeCategories:     os.Getenv("exclude_categories"),
+		TestListPth:           os.Getenv("test_list_path"),
+		RetryFailedTests:      os.Getenv("retry_failed_tests"),
+		TestTimeout:           os.Getenv("test_timeout"),
+		GlobalTimeout:         os.Getenv("global_timeout"),
+		QuarantineListPth:     os.Getenv("quarantine_list_path"),
+		ShardIndex:            os.Getenv("shard_index"),
+		ShardCount:            os.Getenv("shard_count"),
+		ShardTimingPth:        os.Getenv("shard_timing_file"),
 
 		XamarinSolution:      os.Getenv("xamarin_project"),
 		XamarinConfiguration: os.Getenv("xamarin_configuration"),
 		XamarinPlatform:      os.Getenv("xamarin_platform"),
 
-		BuildTool: os.Getenv("build_tool"),
-		DeployDir: os.Getenv("BITRISE_DEPLOY_DIR"),
+		AppPth:          os.Getenv("app_path"),
+		TestAssemblyPth: os.Getenv("test_assembly_path"),
+
+		TestProjects:        os.Getenv("test_projects"),
+		ExcludeTestProjects: os.Getenv("exclude_test_projects"),
+		AppProjects:         os.Getenv("app_projects"),
+		ExcludeAppProjects:  os.Getenv("exclude_app_projects"),
+		TestOrder:           os.Getenv("test_order"),
+
+		BuildTool:       os.Getenv("build_tool"),
+		BuildOptions:    os.Getenv("build_options"),
+		NunitOptions:    os.Getenv("nunit_console_options"),
+		MonoPth:         os.Getenv("mono_path"),
+		MsbuildPth:      os.Getenv("msbuild_path"),
+		XbuildPth:       os.Getenv("xbuild_path"),
+		NunitConsolePth: os.Getenv("nunit_console_path"),
+		DeployDir:       os.Getenv("BITRISE_DEPLOY_DIR"),
 	}
 }
 
@@ -55,7 +130,29 @@ func (configs ConfigsModel) print() {
 
 	log.Printf("- SimulatorDevice: %s", configs.SimulatorDevice)
 	log.Printf("- SimulatorOsVersion: %s", configs.SimulatorOsVersion)
+	log.Printf("- SimulatorDeviceMatrix: %s", configs.SimulatorDeviceMatrix)
+	log.Printf("- CreateSimulator: %s", configs.CreateSimulator)
+	log.Printf("- SimulatorMatchPolicy: %s", configs.SimulatorMatchPolicy)
+	log.Printf("- FallbackDevices: %s", configs.FallbackDevices)
+	log.Printf("- EraseSimulator: %s", configs.EraseSimulator)
+	log.Printf("- CollectDiagnostics: %s", configs.CollectDiagnostics)
+	log.Printf("- RecordSimulator: %s", configs.RecordSimulator)
+	log.Printf("- CollectAttachments: %s", configs.CollectAttachments)
+	log.Printf("- SimulatorBootMode: %s", configs.SimulatorBootMode)
+	log.Printf("- SimulatorBootTimeout: %s", configs.SimulatorBootTimeout)
+	log.Printf("- SimulatorAfterTest: %s", configs.SimulatorAfterTest)
 	log.Printf("- TestToRun: %s", configs.TestToRun)
+	log.Printf("- TestWhere: %s", configs.TestWhere)
+	log.Printf("- IncludeCategories: %s", configs.IncludeCategories)
+	log.Printf("- ExcludeCategories: %s", configs.ExcludeCategories)
+	log.Printf("- TestListPth: %s", configs.TestListPth)
+	log.Printf("- RetryFailedTests: %s", configs.RetryFailedTests)
+	log.Printf("- TestTimeout: %s", configs.TestTimeout)
+	log.Printf("- GlobalTimeout: %s", configs.GlobalTimeout)
+	log.Printf("- QuarantineListPth: %s", configs.QuarantineListPth)
+	log.Printf("- ShardIndex: %s", configs.ShardIndex)
+	log.Printf("- ShardCount: %s", configs.ShardCount)
+	log.Printf("- ShardTimingPth: %s", configs.ShardTimingPth)
 
 	log.Infof("Configs:")
 
@@ -63,9 +160,24 @@ func (configs ConfigsModel) print() {
 	log.Printf("- XamarinConfiguration: %s", configs.XamarinConfiguration)
 	log.Printf("- XamarinPlatform: %s", configs.XamarinPlatform)
 
+	log.Printf("- AppPth: %s", configs.AppPth)
+	log.Printf("- TestAssemblyPth: %s", configs.TestAssemblyPth)
+
+	log.Printf("- TestProjects: %s", configs.TestProjects)
+	log.Printf("- ExcludeTestProjects: %s", configs.ExcludeTestProjects)
+	log.Printf("- AppProjects: %s", configs.AppProjects)
+	log.Printf("- ExcludeAppProjects: %s", configs.ExcludeAppProjects)
+	log.Printf("- TestOrder: %s", configs.TestOrder)
+
 	log.Infof("Debug:")
 
 	log.Printf("- BuildTool: %s", configs.BuildTool)
+	log.Printf("- BuildOptions: %s", configs.BuildOptions)
+	log.Printf("- NunitOptions: %s", configs.NunitOptions)
+	log.Printf("- MonoPth: %s", configs.MonoPth)
+	log.Printf("- MsbuildPth: %s", configs.MsbuildPth)
+	log.Printf("- XbuildPth: %s", configs.XbuildPth)
+	log.Printf("- NunitConsolePth: %s", configs.NunitConsolePth)
 	log.Printf("- DeployDir: %s", configs.DeployDir)
 }
 
@@ -77,81 +189,126 @@ func (configs ConfigsModel) validate() error {
 		return fmt.Errorf("SimulatorOsVersion - %s", err)
 	}
 
-	if err := input.ValidateIfPathExists(configs.XamarinSolution); err != nil {
-		return fmt.Errorf("XamarinSolution - %s", err)
+	if err := input.ValidateWithOptions(configs.CreateSimulator, "yes", "no"); err != nil {
+		return fmt.Errorf("CreateSimulator - %s", err)
 	}
-	if err := input.ValidateIfNotEmpty(configs.XamarinConfiguration); err != nil {
-		return fmt.Errorf("XamarinConfiguration - %s", err)
+	if err := input.ValidateWithOptions(configs.SimulatorMatchPolicy, matchPolicyExact, matchPolicyLatestWithDevice); err != nil {
+		return fmt.Errorf("SimulatorMatchPolicy - %s", err)
 	}
-	if err := input.ValidateIfNotEmpty(configs.XamarinPlatform); err != nil {
-		return fmt.Errorf("XamarinPlatform - %s", err)
+	if err := input.ValidateWithOptions(configs.EraseSimulator, "yes", "no"); err != nil {
+		return fmt.Errorf("EraseSimulator - %s", err)
 	}
-
-	if err := input.ValidateWithOptions(configs.BuildTool, "msbuild", "xbuild"); err != nil {
-		return fmt.Errorf("BuildTool - %s", err)
+	if err := input.ValidateWithOptions(configs.CollectDiagnostics, "yes", "no"); err != nil {
+		return fmt.Errorf("CollectDiagnostics - %s", err)
 	}
-
-	return nil
-}
-
-func getLatestIOSVersion(osVersionSimulatorInfosMap simulator.OsVersionSimulatorInfosMap) (string, error) {
-	var latestVersionPtr *version.Version
-	for osVersion := range osVersionSimulatorInfosMap {
-		if !strings.HasPrefix(osVersion, "iOS") {
-			continue
-		}
-
-		versionStr := strings.TrimPrefix(osVersion, "iOS")
-		versionStr = strings.TrimSpace(versionStr)
-
-		versionPtr, err := version.NewVersion(versionStr)
-		if err != nil {
-			return "", fmt.Errorf("Failed to parse version (%s), error: %s", versionStr, err)
+	if err := input.ValidateWithOptions(configs.RecordSimulator, "yes", "no"); err != nil {
+		return fmt.Errorf("RecordSimulator - %s", err)
+	}
+	if err := input.ValidateWithOptions(configs.CollectAttachments, "yes", "no"); err != nil {
+		return fmt.Errorf("CollectAttachments - %s", err)
+	}
+	if err := input.ValidateWithOptions(configs.SimulatorBootMode, bootModeNone, bootModeSimctl, bootModeSimulatorApp); err != nil {
+		return fmt.Errorf("SimulatorBootMode - %s", err)
+	}
+	if bootTimeout, err := strconv.Atoi(configs.SimulatorBootTimeout); err != nil || bootTimeout <= 0 {
+		return fmt.Errorf("SimulatorBootTimeout - invalid value: %s, should be a positive integer", configs.SimulatorBootTimeout)
+	}
+	if err := input.ValidateWithOptions(configs.SimulatorAfterTest, afterTestKeep, afterTestShutdown, afterTestErase); err != nil {
+		return fmt.Errorf("SimulatorAfterTest - %s", err)
+	}
+	if configs.SimulatorDeviceMatrix != "" {
+		if _, err := parseSimulatorMatrix(configs.SimulatorDeviceMatrix); err != nil {
+			return fmt.Errorf("SimulatorDeviceMatrix - %s", err)
 		}
+	}
 
-		if latestVersionPtr == nil || versionPtr.GreaterThan(latestVersionPtr) {
-			latestVersionPtr = versionPtr
+	if configs.TestListPth != "" {
+		if err := input.ValidateIfPathExists(configs.TestListPth); err != nil {
+			return fmt.Errorf("TestListPth - %s", err)
 		}
 	}
 
-	if latestVersionPtr == nil {
-		return "", fmt.Errorf("Failed to determin latest iOS simulator version")
+	if err := input.ValidateIfNotEmpty(configs.RetryFailedTests); err != nil {
+		return fmt.Errorf("RetryFailedTests - %s", err)
+	}
+	if retryCount, err := strconv.Atoi(configs.RetryFailedTests); err != nil || retryCount < 0 {
+		return fmt.Errorf("RetryFailedTests - invalid value: %s, should be a non-negative integer", configs.RetryFailedTests)
 	}
 
-	versionSegments := latestVersionPtr.Segments()
-	if len(versionSegments) < 2 {
-		return "", fmt.Errorf("Invalid version created: %s, segments count < 2", latestVersionPtr.String())
+	if testTimeout, err := strconv.Atoi(configs.TestTimeout); err != nil || testTimeout < 0 {
+		return fmt.Errorf("TestTimeout - invalid value: %s, should be a non-negative integer", configs.TestTimeout)
+	}
+	if globalTimeout, err := strconv.Atoi(configs.GlobalTimeout); err != nil || globalTimeout < 0 {
+		return fmt.Errorf("GlobalTimeout - invalid value: %s, should be a non-negative integer", configs.GlobalTimeout)
 	}
 
-	return fmt.Sprintf("iOS %d.%d", versionSegments[0], versionSegments[1]), nil
-}
+	if configs.QuarantineListPth != "" {
+		if err := input.ValidateIfPathExists(configs.QuarantineListPth); err != nil {
+			return fmt.Errorf("QuarantineListPth - %s", err)
+		}
+	}
 
-func getSimulatorInfo(osVersion, deviceName string) (simulator.InfoModel, error) {
-	osVersionSimulatorInfosMap, err := simulator.GetOsVersionSimulatorInfosMap()
-	if err != nil {
-		return simulator.InfoModel{}, err
+	shardCount, err := strconv.Atoi(configs.ShardCount)
+	if err != nil || shardCount < 1 {
+		return fmt.Errorf("ShardCount - invalid value: %s, should be a positive integer", configs.ShardCount)
+	}
+	if shardIndex, err := strconv.Atoi(configs.ShardIndex); err != nil || shardIndex < 0 || shardIndex >= shardCount {
+		return fmt.Errorf("ShardIndex - invalid value: %s, should be an integer between 0 and ShardCount - 1", configs.ShardIndex)
+	}
+	if configs.ShardTimingPth != "" {
+		if err := input.ValidateIfPathExists(configs.ShardTimingPth); err != nil {
+			return fmt.Errorf("ShardTimingPth - %s", err)
+		}
 	}
 
-	if osVersion == "latest" {
-		latestOSVersion, err := getLatestIOSVersion(osVersionSimulatorInfosMap)
-		if err != nil {
-			return simulator.InfoModel{}, err
+	if configs.AppPth != "" || configs.TestAssemblyPth != "" {
+		if err := input.ValidateIfPathExists(configs.AppPth); err != nil {
+			return fmt.Errorf("AppPth - %s", err)
+		}
+		if err := input.ValidateIfPathExists(configs.TestAssemblyPth); err != nil {
+			return fmt.Errorf("TestAssemblyPth - %s", err)
+		}
+	} else {
+		if err := input.ValidateIfPathExists(configs.XamarinSolution); err != nil {
+			return fmt.Errorf("XamarinSolution - %s", err)
+		}
+		if err := input.ValidateIfNotEmpty(configs.XamarinConfiguration); err != nil {
+			return fmt.Errorf("XamarinConfiguration - %s", err)
+		}
+		if err := input.ValidateIfNotEmpty(configs.XamarinPlatform); err != nil {
+			return fmt.Errorf("XamarinPlatform - %s", err)
 		}
-		osVersion = latestOSVersion
 	}
 
-	infos, ok := osVersionSimulatorInfosMap[osVersion]
-	if !ok {
-		return simulator.InfoModel{}, fmt.Errorf("No simulators found for os version: %s", osVersion)
+	if err := newNameFilter(configs.TestProjects, configs.ExcludeTestProjects).validatePatterns(); err != nil {
+		return fmt.Errorf("TestProjects - %s", err)
+	}
+	if err := newNameFilter(configs.AppProjects, configs.ExcludeAppProjects).validatePatterns(); err != nil {
+		return fmt.Errorf("AppProjects - %s", err)
 	}
 
-	for _, info := range infos {
-		if info.Name == deviceName {
-			return info, nil
-		}
+	if err := input.ValidateWithOptions(configs.BuildTool, "msbuild", "xbuild"); err != nil {
+		return fmt.Errorf("BuildTool - %s", err)
+	}
+	if _, err := splitArgs(configs.BuildOptions); err != nil {
+		return fmt.Errorf("BuildOptions - %s", err)
+	}
+	if _, err := splitArgs(configs.NunitOptions); err != nil {
+		return fmt.Errorf("NunitOptions - %s", err)
 	}
 
-	return simulator.InfoModel{}, fmt.Errorf("No simulators found for os version: (%s), device name: (%s)", osVersion, deviceName)
+	return nil
+}
+
+// splitCommaSeparatedList returns the trimmed, non empty items of the comma separated list.
+func splitCommaSeparatedList(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 func testResultLogContent(pth string) (string, error) {
@@ -169,31 +326,45 @@ func testResultLogContent(pth string) (string, error) {
 	return content, nil
 }
 
-func parseErrorFromResultLog(content string) (string, error) {
-	failureLineFound := false
-	lastFailureMessage := ""
-
-	scanner := bufio.NewScanner(strings.NewReader(content))
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+func logFailedTestCases(testRun nunitresult.TestRun) {
+	failedTestCases := testRun.FailedTestCases()
+	if len(failedTestCases) == 0 {
+		return
+	}
 
-		if line == "<failure>" {
-			failureLineFound = true
+	log.Errorf("%d test(s) failed:", len(failedTestCases))
+	for _, testCase := range failedTestCases {
+		fmt.Println()
+		log.Errorf("%s", testCase.FullName)
+		if testCase.Failure == nil {
 			continue
 		}
-
-		if failureLineFound && strings.HasPrefix(line, "<message>") {
-			lastFailureMessage = line
+		if message := strings.TrimSpace(testCase.Failure.Message); message != "" {
+			log.Printf("%s", message)
+		}
+		if stackTrace := strings.TrimSpace(testCase.Failure.StackTrace); stackTrace != "" {
+			log.Printf("%s", stackTrace)
 		}
-
-		failureLineFound = false
 	}
+}
+
+// cleanupFuncs are run before the step exits, even if it fails.
+var cleanupFuncs []func()
 
-	return lastFailureMessage, nil
+func addCleanup(fn func()) {
+	cleanupFuncs = append(cleanupFuncs, fn)
+}
+
+func cleanup() {
+	for i := len(cleanupFuncs) - 1; i >= 0; i-- {
+		cleanupFuncs[i]()
+	}
+	cleanupFuncs = nil
 }
 
 func failf(format string, v ...interface{}) {
 	log.Errorf(format, v...)
+	cleanup()
 	if err := tools.ExportEnvironmentWithEnvman("BITRISE_XAMARIN_TEST_RESULT", "failed"); err != nil {
 		log.Warnf("Failed to export environment: %s, error: %s", "BITRISE_XAMARIN_TEST_RESULT", err)
 	}
@@ -210,85 +381,173 @@ func main() {
 		failf("Issue with input: %s", err)
 	}
 
-	// Get Simulator Infos
-	fmt.Println()
-	log.Infof("Collecting simulator info...")
-	simulatorInfo, err := getSimulatorInfo(configs.SimulatorOsVersion, configs.SimulatorDevice)
+	retryCount, err := strconv.Atoi(configs.RetryFailedTests)
 	if err != nil {
-		failf("Failed to get simulator infos, error: %s", err)
+		failf("Failed to parse RetryFailedTests (%s), error: %s", configs.RetryFailedTests, err)
 	}
-	log.Donef("Simulator (%s), id: (%s), status: %s", simulatorInfo.Name, simulatorInfo.ID, simulatorInfo.Status)
 
-	if err := os.Setenv("IOS_SIMULATOR_UDID", simulatorInfo.ID); err != nil {
-		failf("Failed to export simulator UDID, error: %s", err)
+	testTimeout, err := strconv.Atoi(configs.TestTimeout)
+	if err != nil {
+		failf("Failed to parse TestTimeout (%s), error: %s", configs.TestTimeout, err)
 	}
 
-	// ---
+	globalTimeout, err := strconv.Atoi(configs.GlobalTimeout)
+	if err != nil {
+		failf("Failed to parse GlobalTimeout (%s), error: %s", configs.GlobalTimeout, err)
+	}
 
-	// Nunit Console path
-	nunitConsolePth, err := nunit.SystemNunit3ConsolePath()
+	bootTimeout, err := strconv.Atoi(configs.SimulatorBootTimeout)
 	if err != nil {
-		failf("Failed to get system insatlled nunit3-console.exe path, error: %s", err)
+		failf("Failed to parse SimulatorBootTimeout (%s), error: %s", configs.SimulatorBootTimeout, err)
 	}
-	// ---
 
-	//
-	// build
+	shardIndex, err := strconv.Atoi(configs.ShardIndex)
+	if err != nil {
+		failf("Failed to parse ShardIndex (%s), error: %s", configs.ShardIndex, err)
+	}
+
+	shardCount, err := strconv.Atoi(configs.ShardCount)
+	if err != nil {
+		failf("Failed to parse ShardCount (%s), error: %s", configs.ShardCount, err)
+	}
+
+	timings, err := readTestTimings(configs.ShardTimingPth)
+	if err != nil {
+		failf("Failed to read test timings, error: %s", err)
+	}
+
+	quarantined, err := readQuarantineList(configs.QuarantineListPth)
+	if err != nil {
+		failf("Failed to read quarantine list, error: %s", err)
+	}
+	if len(quarantined) > 0 {
+		log.Printf("%d quarantined test(s)", len(quarantined))
+	}
+
+	// Get Simulator Infos
 	fmt.Println()
-	log.Infof("Building all iOS Xamarin UITest and Referred Projects in solution: %s", configs.XamarinSolution)
+	log.Infof("Collecting simulator info...")
 
-	buildTool := buildtools.Msbuild
-	if configs.BuildTool == "xbuild" {
-		buildTool = buildtools.Xbuild
+	simulatorSpecs := []simulatorSpec{{device: configs.SimulatorDevice, osVersion: configs.SimulatorOsVersion}}
+	if configs.SimulatorDeviceMatrix != "" {
+		simulatorSpecs, err = parseSimulatorMatrix(configs.SimulatorDeviceMatrix)
+		if err != nil {
+			failf("Failed to parse simulator device matrix, error: %s", err)
+		}
 	}
 
-	builder, err := builder.New(configs.XamarinSolution, []constants.SDK{constants.SDKIOS}, buildTool)
+	inventory, err := simctl.List()
 	if err != nil {
-		failf("Failed to create xamarin builder, error: %s", err)
+		failf("Failed to list simulators, error: %s", err)
 	}
 
-	callback := func(solutionName string, projectName string, sdk constants.SDK, testFramework constants.TestFramework, commandStr string, alreadyPerformed bool) {
-		fmt.Println()
-		if testFramework == constants.TestFrameworkXamarinUITest {
-			log.Infof("Building test project: %s", projectName)
-		} else {
-			log.Infof("Building project: %s", projectName)
+	fallbackDevices := splitCommaSeparatedList(configs.FallbackDevices)
+
+	simulatorTargets := []simulatorTarget{}
+	simulatorIDs := map[string]bool{}
+	for _, spec := range simulatorSpecs {
+		if configs.CreateSimulator == "yes" {
+			target, err := createSimulator(inventory, spec, configs.SimulatorMatchPolicy)
+			if err != nil {
+				printAvailableSimulators(inventory)
+				failf("Failed to create simulator, error: %s", err)
+			}
+			log.Donef("Simulator (%s) created, id: (%s), os: (%s)", target.info.Name, target.info.ID, target.osVersion)
+
+			addCleanup(func() {
+				fmt.Println()
+				log.Infof("Deleting simulator: %s", target.info.ID)
+				if err := simctl.Delete(target.info.ID); err != nil {
+					log.Warnf("Failed to delete simulator, error: %s", err)
+				}
+			})
+
+			simulatorTargets = append(simulatorTargets, target)
+			continue
 		}
 
-		log.Donef("$ %s", commandStr)
+		log.Printf("selecting simulator: %s (%s)", spec.device, spec.osVersion)
+		simulatorInfo, simulatorOsVersion, err := selectSimulator(inventory, spec, configs.SimulatorMatchPolicy, fallbackDevices)
+		if err != nil {
+			printAvailableSimulators(inventory)
+			failf("Failed to get simulator infos, error: %s", err)
+		}
+		log.Donef("Simulator (%s), id: (%s), os: (%s), status: %s", simulatorInfo.Name, simulatorInfo.ID, simulatorOsVersion, simulatorInfo.Status)
 
-		if alreadyPerformed {
-			log.Warnf("build command already performed, skipping...")
+		if simulatorIDs[simulatorInfo.ID] {
+			log.Warnf("Simulator (%s) already selected, skipping...", simulatorInfo.ID)
+			continue
 		}
+		simulatorIDs[simulatorInfo.ID] = true
 
-		fmt.Println()
+		simulatorTargets = append(simulatorTargets, simulatorTarget{info: simulatorInfo, osVersion: simulatorOsVersion})
 	}
 
-	startTime := time.Now()
-	warnings, err := builder.BuildAndRunAllXamarinUITestAndReferredProjects(configs.XamarinConfiguration, configs.XamarinPlatform, nil, callback)
-	endTime := time.Now()
+	// ---
 
-	for _, warning := range warnings {
-		log.Warnf(warning)
-	}
-	if err != nil {
-		failf("Build failed, error: %s", err)
-	}
+	// Tools
+	fmt.Println()
+	log.Infof("Resolving tools")
 
-	projectOutputMap, err := builder.CollectProjectOutputs(configs.XamarinConfiguration, configs.XamarinPlatform, startTime, endTime)
+	nunitConsolePth, err := configureTools(configs)
 	if err != nil {
-		failf("Failed to collect project outputs, error: %s", err)
+		failf("Failed to resolve tools, error: %s", err)
 	}
+	// ---
 
-	testProjectOutputMap, warnings, err := builder.CollectXamarinUITestProjectOutputs(configs.XamarinConfiguration, configs.XamarinPlatform, startTime, endTime)
-	for _, warning := range warnings {
-		log.Warnf(warning)
+	//
+	// Test pairs
+	testProjectFilter := newNameFilter(configs.TestProjects, configs.ExcludeTestProjects)
+	projectFilter := newNameFilter(configs.AppProjects, configs.ExcludeAppProjects)
+
+	var testPairs []testPair
+	if configs.AppPth != "" {
+		fmt.Println()
+		log.Infof("Using prebuilt artifacts, skipping build")
+
+		testPairs, err = filterTestPairs([]testPair{prebuiltTestPair(configs.TestAssemblyPth, configs.AppPth)}, testProjectFilter, projectFilter)
+		if err != nil {
+			failf("Failed to select test projects, error: %s", err)
+		}
+	} else {
+		pairs, err := buildTestPairs(configs, testProjectFilter, projectFilter)
+		if err != nil {
+			failf("%s", err)
+		}
+		testPairs = pairs
 	}
-	if err != nil {
-		failf("Failed to collect test project output, error: %s", err)
+
+	sortTestPairs(testPairs, splitCommaSeparatedList(configs.TestOrder))
+	// ---
+
+	//
+	// Prepare simulators
+	if configs.EraseSimulator == "yes" || configs.SimulatorBootMode != bootModeNone || configs.SimulatorAfterTest != afterTestKeep {
+		fmt.Println()
+		log.Infof("Preparing simulators")
 	}
-	if len(testProjectOutputMap) == 0 {
-		failf("No testable output generated")
+
+	for _, target := range simulatorTargets {
+		info := target.info
+
+		if configs.SimulatorAfterTest != afterTestKeep {
+			addCleanup(func() {
+				if err := teardownSimulator(info, configs.SimulatorAfterTest); err != nil {
+					log.Warnf("%s", err)
+				}
+			})
+		}
+
+		if configs.EraseSimulator == "yes" {
+			log.Printf("erasing simulator: %s (%s)", info.Name, info.ID)
+			if err := simctl.Erase(info.ID); err != nil {
+				failf("Failed to erase simulator (%s), error: %s", info.ID, err)
+			}
+		}
+
+		if err := bootSimulator(info, configs.SimulatorBootMode, time.Duration(bootTimeout)*time.Second); err != nil {
+			failf("%s", err)
+		}
 	}
 	// ---
 
@@ -299,77 +558,128 @@ func main() {
 		failf("Failed to create nunit console model, error: %s", err)
 	}
 
-	resultLogPth := filepath.Join(configs.DeployDir, "TestResult.xml")
-	nunitConsole.SetResultLogPth(resultLogPth)
+	nunitOptions, err := splitArgs(configs.NunitOptions)
+	if err != nil {
+		failf("Failed to parse NunitOptions (%s), error: %s", configs.NunitOptions, err)
+	}
+	nunitConsole.SetCustomOptions(nunitOptions...)
+
+	nunitConsole.SetWhere(configs.TestWhere)
+	nunitConsole.SetIncludeCategories(splitCommaSeparatedList(configs.IncludeCategories)...)
+	nunitConsole.SetExcludeCategories(splitCommaSeparatedList(configs.ExcludeCategories)...)
+	nunitConsole.SetTestListPth(configs.TestListPth)
+	nunitConsole.SetTestTimeout(time.Duration(testTimeout) * time.Second)
+	if globalTimeout > 0 {
+		// every nunit console run, including the retries, has to finish until the deadline
+		nunitConsole.SetDeadline(time.Now().Add(time.Duration(globalTimeout) * time.Second))
+	}
+	if err := nunitConsole.Validate(); err != nil {
+		failf("Invalid test selection, error: %s", err)
+	}
 
 	// Artifacts
-	resultLog := ""
+	artifacts := newArtifacts(configs.DeployDir)
 
-	for testProjectName, testProjectOutput := range testProjectOutputMap {
-		if len(testProjectOutput.ReferredProjectNames) == 0 {
-			log.Warnf("Test project (%s) does not refers to any project, skipping...", testProjectName)
-			continue
+	for _, pair := range testPairs {
+		// Set APP_BUNDLE_PATH env to let the test know which .app file should be tested
+		// This env is used in the Xamarin.UITest project to refer to the .app path
+		if err := os.Setenv("APP_BUNDLE_PATH", pair.appPth); err != nil {
+			failf("Failed to set APP_BUNDLE_PATH environment, without this env test will fail, error: %s", err)
 		}
 
-		for _, projectName := range testProjectOutput.ReferredProjectNames {
-			projectOutput, ok := projectOutputMap[projectName]
-			if !ok {
+		// Run test
+		fmt.Println()
+		log.Infof("Testing (%s) against (%s)", pair.testProjectName, pair.projectName)
+		log.Printf("test dll: %s", pair.testDllPth)
+		log.Printf("app: %s", pair.appPth)
+
+		nunitConsole.SetDLLPth(pair.testDllPth)
+		nunitConsole.SetTestToRun(configs.TestToRun)
+		nunitConsole.SetTestListPth(configs.TestListPth)
+
+		if shardCount > 1 {
+			fmt.Println()
+			log.Infof("Collecting the tests of shard %d/%d", shardIndex, shardCount)
+
+			shardTestListPth, err := shardTestList(nunitConsole, shardIndex, shardCount, timings)
+			if err != nil {
+				artifacts.export()
+				failf("Failed to shard tests, error: %s", err)
+			}
+			if shardTestListPth == "" {
+				log.Warnf("No test of (%s) belongs to shard %d/%d, skipping...", pair.testProjectName, shardIndex, shardCount)
 				continue
 			}
 
-			appPth := ""
-			for _, output := range projectOutput.Outputs {
-				if output.OutputType == constants.OutputTypeAPP {
-					appPth = output.Pth
+			// the shard's test list already respects the test name selection
+			nunitConsole.SetTestToRun("")
+			nunitConsole.SetTestListPth(shardTestListPth)
+		}
+
+		options := testRunOptions{
+			retryCount:         retryCount,
+			collectDiagnostics: configs.CollectDiagnostics == "yes",
+			recordSimulator:    configs.RecordSimulator == "yes",
+		}
+		if options.collectDiagnostics {
+			bundleID, err := bundleIDOfApp(pair.appPth)
+			if err != nil {
+				log.Warnf("Failed to read bundle id of the app, every new crash report will be collected, error: %s", err)
+			}
+			options.bundleID = bundleID
+		}
+		if configs.CollectAttachments == "yes" {
+			options.collectAttachments = true
+
+			// Xamarin.UITest saves the screenshots into the working directory or next to the test assembly,
+			// which are shared by the parallel runs of a device matrix: their screenshots can not be told apart.
+			if len(simulatorTargets) > 1 {
+				log.Warnf("Tests run on %d simulators in parallel, only the attachments referenced in the test results are collected", len(simulatorTargets))
+			} else {
+				options.screenshotDirs = []string{filepath.Dir(pair.testDllPth)}
+				if workDir, err := os.Getwd(); err != nil {
+					log.Warnf("Failed to get working directory, error: %s", err)
+				} else if workDir != options.screenshotDirs[0] {
+					options.screenshotDirs = append(options.screenshotDirs, workDir)
 				}
 			}
+		}
 
-			if appPth == "" {
-				failf("No app generated for project: %s", projectName)
-			}
+		outcomes := runTestsOnSimulators(nunitConsole, artifacts, pair.testProjectName, pair.projectName, simulatorTargets, options)
 
-			// Set APP_BUNDLE_PATH env to let the test know which .app file should be tested
-			// This env is used in the Xamarin.UITest project to refer to the .app path
-			if err := os.Setenv("APP_BUNDLE_PATH", appPth); err != nil {
-				failf("Failed to set APP_BUNDLE_PATH environment, without this env test will fail, error: %s", err)
+		failedOutcomes := []testOutcome{}
+		for i, outcome := range outcomes {
+			outcome.applyQuarantine(quarantined)
+			if outcome.resultLog != "" {
+				artifacts.addResultLog(outcome.resultLog)
 			}
 
-			// Run test
-			fmt.Println()
-			log.Infof("Testing (%s) against (%s)", testProjectName, projectName)
-			log.Printf("test dll: %s", testProjectOutput.Output.Pth)
-			log.Printf("app: %s", appPth)
-
-			nunitConsole.SetDLLPth(testProjectOutput.Output.Pth)
-			nunitConsole.SetTestToRun(configs.TestToRun)
+			target := simulatorTargets[i]
+			artifacts.addTestRunSummary(newTestRunSummary(pair.testProjectName, pair.projectName, pair.appPth, newSimulatorSummary(target.info, target.osVersion), outcome))
 
-			fmt.Println()
-			log.Infof("Running Xamarin UITest")
-			log.Donef("$ %s", nunitConsole.PrintableCommand())
-			fmt.Println()
+			if outcome.testRun != nil {
+				if err := artifacts.addJUnitResult(outcome.id, *outcome.testRun); err != nil {
+					log.Warnf("Failed to export junit test result, error: %s", err)
+				}
+			}
 
-			err := nunitConsole.Run()
-			testLog, readErr := testResultLogContent(resultLogPth)
-			if readErr != nil {
-				log.Warnf("Failed to read test result, error: %s", readErr)
+			if outcome.err != nil {
+				failedOutcomes = append(failedOutcomes, outcome)
 			}
-			resultLog = testLog
+		}
 
-			if err != nil {
-				if errorMsg, err := parseErrorFromResultLog(resultLog); err != nil {
-					log.Warnf("Failed to parse error message from result log, error: %s", err)
-				} else if errorMsg != "" {
-					log.Errorf("%s", errorMsg)
+		if len(failedOutcomes) > 0 {
+			for _, outcome := range failedOutcomes {
+				fmt.Println()
+				log.Errorf("Test failed: %s, error: %s", outcome.id, outcome.err)
+				if outcome.testRun != nil {
+					logFailedTestCases(*outcome.testRun)
 				}
+			}
 
-				if resultLog != "" {
-					if err := tools.ExportEnvironmentWithEnvman("BITRISE_XAMARIN_TEST_FULL_RESULTS_TEXT", resultLog); err != nil {
-						log.Warnf("Failed to export environment: %s, error: %s", "BITRISE_XAMARIN_TEST_FULL_RESULTS_TEXT", err)
-					}
-				}
+			artifacts.export()
 
-				failf("Test failed, error: %s", err)
-			}
+			failf("Test failed, error: %s", failedOutcomes[0].err)
 		}
 	}
 
@@ -377,9 +687,94 @@ func main() {
 		log.Warnf("Failed to export environment: %s, error: %s", "BITRISE_XAMARIN_TEST_RESULT", err)
 	}
 
-	if resultLog != "" {
-		if err := tools.ExportEnvironmentWithEnvman("BITRISE_XAMARIN_TEST_FULL_RESULTS_TEXT", resultLog); err != nil {
-			log.Warnf("Failed to export environment: %s, error: %s", "BITRISE_XAMARIN_TEST_FULL_RESULTS_TEXT", err)
+	artifacts.export()
+
+	cleanup()
+}
+
+// buildTestPairs builds the Xamarin UITest projects with their referred projects selected by the filters,
+// then returns the test projects paired with the apps to test.
+func buildTestPairs(configs ConfigsModel, testProjectFilter, projectFilter nameFilter) ([]testPair, error) {
+	fmt.Println()
+	log.Infof("Building all iOS Xamarin UITest and Referred Projects in solution: %s", configs.XamarinSolution)
+
+	buildTool := buildtools.Msbuild
+	if configs.BuildTool == "xbuild" {
+		buildTool = buildtools.Xbuild
+	}
+
+	buildOptions, err := splitArgs(configs.BuildOptions)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse BuildOptions (%s), error: %s", configs.BuildOptions, err)
+	}
+
+	var prepareCallback builder.PrepareCommandCallback
+	if len(buildOptions) > 0 {
+		prepareCallback = func(solutionName string, projectName string, sdk constants.SDK, testFramework constants.TestFramework, command *xamarintools.Editable) {
+			(*command).SetCustomOptions(buildOptions...)
 		}
 	}
+
+	builder, err := builder.New(configs.XamarinSolution, []constants.SDK{constants.SDKIOS}, buildTool)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create xamarin builder, error: %s", err)
+	}
+
+	// the filters are checked before the build, to fail fast on a mistyped project name
+	testProjectNames, projectNames := builder.XamarinUITestProjectNames(configs.XamarinConfiguration, configs.XamarinPlatform)
+	if err := testProjectFilter.checkUnmatched(testProjectNames); err != nil {
+		return nil, fmt.Errorf("Failed to select test projects, error: Test project %s", err)
+	}
+	if err := projectFilter.checkUnmatched(projectNames); err != nil {
+		return nil, fmt.Errorf("Failed to select test projects, error: App project %s", err)
+	}
+	builder.SetProjectFilters(testProjectFilter.matches, projectFilter.matches)
+
+	callback := func(solutionName string, projectName string, sdk constants.SDK, testFramework constants.TestFramework, commandStr string, alreadyPerformed bool) {
+		fmt.Println()
+		if projectName == "" {
+			log.Infof("Building solution: %s", solutionName)
+		} else if testFramework == constants.TestFrameworkXamarinUITest {
+			log.Infof("Building test project: %s", projectName)
+		} else {
+			log.Infof("Building project: %s", projectName)
+		}
+
+		log.Donef("$ %s", commandStr)
+
+		if alreadyPerformed {
+			log.Warnf("build command already performed, skipping...")
+		}
+
+		fmt.Println()
+	}
+
+	startTime := time.Now()
+	warnings, err := builder.BuildAndRunAllXamarinUITestAndReferredProjects(configs.XamarinConfiguration, configs.XamarinPlatform, prepareCallback, callback)
+	endTime := time.Now()
+
+	for _, warning := range warnings {
+		log.Warnf(warning)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Build failed, error: %s", err)
+	}
+
+	projectOutputMap, err := builder.CollectProjectOutputs(configs.XamarinConfiguration, configs.XamarinPlatform, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to collect project outputs, error: %s", err)
+	}
+
+	testProjectOutputMap, warnings, err := builder.CollectXamarinUITestProjectOutputs(configs.XamarinConfiguration, configs.XamarinPlatform, startTime, endTime)
+	for _, warning := range warnings {
+		log.Warnf(warning)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to collect test project output, error: %s", err)
+	}
+	if len(testProjectOutputMap) == 0 {
+		return nil, fmt.Errorf("No testable output generated")
+	}
+
+	return builtTestPairs(testProjectOutputMap, projectOutputMap, testProjectFilter, projectFilter)
 }
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/simctl"
+	"github.com/bitrise-tools/go-xcode/simulator"
+	version "github.com/hashicorp/go-version"
+)
+
+// Simulator matching policies
+const (
+	// matchPolicyExact uses the given os version, latest means the latest iOS runtime
+	matchPolicyExact = "exact"
+	// matchPolicyLatestWithDevice falls back to older runtimes if the device does not exist for the latest (or highest matching) one
+	matchPolicyLatestWithDevice = "latest_with_device"
+)
+
+func runtimeVersion(runtime simctl.Runtime) (*version.Version, error) {
+	versionStr := runtime.Version
+	if versionStr == "" {
+		versionStr = runtime.Identifier.Version()
+	}
+	return version.NewVersion(versionStr)
+}
+
+// runtimeUnavailableReason describes why the runtime is unavailable: runtime is unavailable (runtime profile not found).
+func runtimeUnavailableReason(runtime simctl.Runtime) string {
+	if message := runtime.AvailabilityMessage(); message != "" {
+		return fmt.Sprintf("runtime is unavailable (%s)", message)
+	}
+	return "runtime is unavailable"
+}
+
+// isVersionConstraint reports if the os version is a version constraint (>= 12.0, iOS ~> 12.0) rather than an exact version.
+func isVersionConstraint(osVersion string) bool {
+	osVersion = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(osVersion), "iOS"))
+	return strings.IndexAny(osVersion, "<>=!~") == 0
+}
+
+// iOSRuntimes returns the iOS runtimes of the inventory, highest version first.
+func iOSRuntimes(inventory simctl.Inventory) []simctl.Runtime {
+	type versionedRuntime struct {
+		runtime simctl.Runtime
+		version *version.Version
+	}
+
+	versionedRuntimes := []versionedRuntime{}
+	for _, runtime := range inventory.Runtimes {
+		if runtime.Platform() != "iOS" {
+			continue
+		}
+		if !runtime.Available() {
+			log.Printf("- %s: rejected, %s", runtime.OSVersion(), runtimeUnavailableReason(runtime))
+			continue
+		}
+
+		v, err := runtimeVersion(runtime)
+		if err != nil {
+			log.Warnf("Failed to parse version of runtime (%s), error: %s", runtime.Identifier, err)
+			continue
+		}
+		versionedRuntimes = append(versionedRuntimes, versionedRuntime{runtime: runtime, version: v})
+	}
+
+	sort.SliceStable(versionedRuntimes, func(i, j int) bool {
+		return versionedRuntimes[i].version.GreaterThan(versionedRuntimes[j].version)
+	})
+
+	runtimes := []simctl.Runtime{}
+	for _, versionedRuntime := range versionedRuntimes {
+		runtimes = append(runtimes, versionedRuntime.runtime)
+	}
+	return runtimes
+}
+
+// candidateRuntimes returns the iOS runtimes matching the os version, highest version first.
+// The os version is either latest, an exact os version (iOS 12.1) or a version constraint (>= 12.0).
+func candidateRuntimes(inventory simctl.Inventory, osVersion, policy string) ([]simctl.Runtime, error) {
+	runtimes := iOSRuntimes(inventory)
+
+	switch {
+	case osVersion == "latest":
+		if len(runtimes) == 0 {
+			return nil, fmt.Errorf("Failed to determin latest iOS simulator version, no iOS runtime found")
+		}
+		if policy == matchPolicyExact {
+			return runtimes[:1], nil
+		}
+		return runtimes, nil
+	case isVersionConstraint(osVersion):
+		constraintStr := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(osVersion), "iOS"))
+		constraints, err := version.NewConstraint(constraintStr)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid os version constraint (%s), error: %s", osVersion, err)
+		}
+
+		matching := []simctl.Runtime{}
+		for _, runtime := range runtimes {
+			v, err := runtimeVersion(runtime)
+			if err != nil {
+				continue
+			}
+			if !constraints.Check(v) {
+				log.Printf("- %s: rejected, does not satisfy %s", runtime.OSVersion(), constraintStr)
+				continue
+			}
+			matching = append(matching, runtime)
+		}
+
+		if len(matching) == 0 {
+			return nil, fmt.Errorf("No iOS runtime found for os version constraint: %s", osVersion)
+		}
+		if policy == matchPolicyExact {
+			return matching[:1], nil
+		}
+		return matching, nil
+	default:
+		runtime, ok := inventory.Runtime(osVersion)
+		if !ok {
+			return nil, fmt.Errorf("No simulators found for os version: %s", osVersion)
+		}
+		if !runtime.Available() {
+			return nil, fmt.Errorf("Runtime for os version (%s) is %s", osVersion, runtimeUnavailableReason(runtime))
+		}
+		return []simctl.Runtime{runtime}, nil
+	}
+}
+
+func simulatorInfoFromDevice(device simctl.Device) simulator.InfoModel {
+	info := simulator.InfoModel{
+		Name:   device.Name,
+		ID:     device.UDID,
+		Status: device.State,
+	}
+	if !device.Available() {
+		info.StatusOther = "unavailable"
+		if message := device.AvailabilityMessage(); message != "" {
+			info.StatusOther += ", " + message
+		}
+	}
+	return info
+}
+
+// selectSimulator returns the first available simulator matching the spec, trying the device first then the fallback devices in order,
+// each on the candidate runtimes, highest version first.
+func selectSimulator(inventory simctl.Inventory, spec simulatorSpec, policy string, fallbackDevices []string) (simulator.InfoModel, string, error) {
+	runtimes, err := candidateRuntimes(inventory, spec.osVersion, policy)
+	if err != nil {
+		return simulator.InfoModel{}, "", err
+	}
+
+	deviceNames := append([]string{spec.device}, fallbackDevices...)
+	for _, deviceName := range deviceNames {
+		for _, runtime := range runtimes {
+			found := false
+			for _, device := range inventory.RuntimeDevices(runtime) {
+				if device.Name != deviceName {
+					continue
+				}
+				found = true
+
+				info := simulatorInfoFromDevice(device)
+				if info.StatusOther != "" {
+					log.Printf("- %s on %s (%s): rejected, %s", deviceName, runtime.OSVersion(), device.UDID, info.StatusOther)
+					continue
+				}
+
+				return info, runtime.OSVersion(), nil
+			}
+
+			if !found {
+				log.Printf("- %s on %s: rejected, no such simulator", deviceName, runtime.OSVersion())
+			}
+		}
+	}
+
+	return simulator.InfoModel{}, "", fmt.Errorf("No simulators found for os version: (%s), device name(s): (%s)", spec.osVersion, strings.Join(deviceNames, ", "))
+}
+
+// formatTable aligns the tab separated columns of the header and the rows.
+func formatTable(header string, rows []string) (string, error) {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	for _, line := range append([]string{header}, rows...) {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return "", err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// printAvailableSimulators prints the available iOS runtimes with their usable devices and the device types,
+// to help picking a valid simulator_device and simulator_os_version.
+func printAvailableSimulators(inventory simctl.Inventory) {
+	runtimeRows := []string{}
+	for _, runtime := range inventory.Runtimes {
+		if runtime.Platform() != "iOS" || !runtime.Available() {
+			continue
+		}
+
+		deviceNames := []string{}
+		for _, device := range inventory.RuntimeDevices(runtime) {
+			if device.Available() {
+				deviceNames = append(deviceNames, device.Name)
+			}
+		}
+		runtimeRows = append(runtimeRows, fmt.Sprintf("%s\t%s\t%s", runtime.OSVersion(), runtime.Identifier, strings.Join(deviceNames, ", ")))
+	}
+
+	log.Printf("Available iOS runtimes:")
+	if table, err := formatTable("OS VERSION\tRUNTIME\tDEVICES", runtimeRows); err != nil {
+		log.Warnf("Failed to print runtimes, error: %s", err)
+	} else {
+		log.Printf("%s", table)
+	}
+
+	deviceTypeRows := []string{}
+	for _, deviceType := range inventory.DeviceTypes {
+		deviceTypeRows = append(deviceTypeRows, deviceType.Name+"\t"+deviceType.Identifier)
+	}
+
+	log.Printf("Available device types:")
+	if table, err := formatTable("NAME\tIDENTIFIER", deviceTypeRows); err != nil {
+		log.Warnf("Failed to print device types, error: %s", err)
+	} else {
+		log.Printf("%s", table)
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/simctl"
+	"github.com/bitrise-tools/go-xcode/simulator"
+)
+
+func TestFormatTable(t *testing.T) {
+	table, err := formatTable("NAME\tIDENTIFIER", []string{
+		"iPhone 8\tcom.apple.CoreSimulator.SimDeviceType.iPhone-8",
+		"iPad Pro (9.7-inch)\tcom.apple.CoreSimulator.SimDeviceType.iPad-Pro--9-7-inch-",
+	})
+	if err != nil {
+		t.Fatalf("formatTable() error: %s", err)
+	}
+
+	want := "NAME                 IDENTIFIER\n" +
+		"iPhone 8             com.apple.CoreSimulator.SimDeviceType.iPhone-8\n" +
+		"iPad Pro (9.7-inch)  com.apple.CoreSimulator.SimDeviceType.iPad-Pro--9-7-inch-\n"
+	if table != want {
+		t.Errorf("formatTable() =\n%s\nwant\n%s", table, want)
+	}
+}
+
+func inventoryFixture(t *testing.T, name string) simctl.Inventory {
+	content, err := ioutil.ReadFile(filepath.Join("simctl", "testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture (%s), error: %s", name, err)
+	}
+
+	inventory, err := simctl.ParseInventory(content)
+	if err != nil {
+		t.Fatalf("ParseInventory(%s) error: %s", name, err)
+	}
+	return inventory
+}
+
+// captureLog returns what fn logs.
+func captureLog(fn func()) string {
+	var buf bytes.Buffer
+	log.SetOutWriter(&buf)
+	defer log.SetOutWriter(os.Stdout)
+
+	fn()
+	return buf.String()
+}
+
+func TestCandidateRuntimes(t *testing.T) {
+	tests := []struct {
+		name      string
+		fixture   string
+		osVersion string
+		policy    string
+		want      []string
+		wantErr   bool
+		wantLog   []string
+	}{
+		{
+			name:      "exact",
+			fixture:   "list_xcode10.2_runtimes.json",
+			osVersion: "iOS 12.1",
+			policy:    matchPolicyExact,
+			want:      []string{"iOS 12.1"},
+		},
+		{
+			name:      "exact, unknown os version",
+			fixture:   "list_xcode10.2_runtimes.json",
+			osVersion: "iOS 12.0",
+			policy:    matchPolicyExact,
+			wantErr:   true,
+		},
+		{
+			name:      "exact, unavailable runtime",
+			fixture:   "list_xcode10.1.json",
+			osVersion: "iOS 12.0",
+			policy:    matchPolicyExact,
+			wantErr:   true,
+		},
+		{
+			name:      "latest, exact",
+			fixture:   "list_xcode10.2_runtimes.json",
+			osVersion: "latest",
+			policy:    matchPolicyExact,
+			want:      []string{"iOS 12.2"},
+		},
+		{
+			name:      "latest skips unavailable runtimes, availability error",
+			fixture:   "list_xcode10.2.json",
+			osVersion: "latest",
+			policy:    matchPolicyLatestWithDevice,
+			want:      []string{"iOS 12.2"},
+			wantLog:   []string{"- iOS 12.1: rejected, runtime is unavailable (runtime profile not found)"},
+		},
+		{
+			name:      "latest with device",
+			fixture:   "list_xcode10.2_runtimes.json",
+			osVersion: "latest",
+			policy:    matchPolicyLatestWithDevice,
+			want:      []string{"iOS 12.2", "iOS 12.1", "iOS 11.4"},
+		},
+		{
+			name:      "latest skips unavailable runtimes",
+			fixture:   "list_xcode10.0.json",
+			osVersion: "latest",
+			policy:    matchPolicyLatestWithDevice,
+			want:      []string{"iOS 12.0"},
+			wantLog:   []string{"- iOS 11.3: rejected, runtime is unavailable (runtime profile not found)"},
+		},
+		{
+			name:      "at least, latest with device",
+			fixture:   "list_xcode10.2_runtimes.json",
+			osVersion: ">= 12.0",
+			policy:    matchPolicyLatestWithDevice,
+			want:      []string{"iOS 12.2", "iOS 12.1"},
+			wantLog:   []string{"- iOS 11.4: rejected, does not satisfy >= 12.0"},
+		},
+		{
+			name:      "pessimistic, exact",
+			fixture:   "list_xcode10.2_runtimes.json",
+			osVersion: "iOS ~> 12.0",
+			policy:    matchPolicyExact,
+			want:      []string{"iOS 12.2"},
+		},
+		{
+			name:      "pessimistic, older major",
+			fixture:   "list_xcode10.2_runtimes.json",
+			osVersion: "~> 11.0",
+			policy:    matchPolicyLatestWithDevice,
+			want:      []string{"iOS 11.4"},
+		},
+		{
+			name:      "no matching runtime",
+			fixture:   "list_xcode10.2_runtimes.json",
+			osVersion: ">= 13.0",
+			policy:    matchPolicyLatestWithDevice,
+			wantErr:   true,
+		},
+		{
+			name:      "invalid constraint",
+			fixture:   "list_xcode10.2_runtimes.json",
+			osVersion: ">= twelve",
+			policy:    matchPolicyLatestWithDevice,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inventory := inventoryFixture(t, tt.fixture)
+
+			var runtimes []simctl.Runtime
+			var err error
+			out := captureLog(func() {
+				runtimes, err = candidateRuntimes(inventory, tt.osVersion, tt.policy)
+			})
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("candidateRuntimes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			got := []string{}
+			for _, runtime := range runtimes {
+				got = append(got, runtime.OSVersion())
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("candidateRuntimes() = %v, want %v", got, tt.want)
+			}
+
+			for _, line := range tt.wantLog {
+				if !strings.Contains(out, line) {
+					t.Errorf("log does not contain (%s):\n%s", line, out)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectSimulator(t *testing.T) {
+	tests := []struct {
+		name            string
+		fixture         string
+		spec            simulatorSpec
+		policy          string
+		fallbackDevices []string
+		wantID          string
+		wantOSVersion   string
+		wantErr         bool
+		wantLog         []string
+	}{
+		{
+			name:          "exact",
+			fixture:       "list_xcode10.0.json",
+			spec:          simulatorSpec{device: "iPhone XS", osVersion: "iOS 12.0"},
+			policy:        matchPolicyExact,
+			wantID:        "7A2E9C4D-1F3B-4C5A-9E8D-0B1C2D3E4F03",
+			wantOSVersion: "iOS 12.0",
+		},
+		{
+			name:          "latest, devices keyed by identifier",
+			fixture:       "list_xcode10.2.json",
+			spec:          simulatorSpec{device: "iPhone 8", osVersion: "latest"},
+			policy:        matchPolicyExact,
+			wantID:        "4D5E6F7A-8B9C-4D0E-1F2A-3B4C5D6E7F08",
+			wantOSVersion: "iOS 12.2",
+		},
+		{
+			name:    "latest, exact, missing device",
+			fixture: "list_xcode10.2_runtimes.json",
+			spec:    simulatorSpec{device: "iPhone 8", osVersion: "latest"},
+			policy:  matchPolicyExact,
+			wantErr: true,
+			wantLog: []string{"- iPhone 8 on iOS 12.2: rejected, no such simulator"},
+		},
+		{
+			name:          "latest with device skips missing and unavailable devices",
+			fixture:       "list_xcode10.2_runtimes.json",
+			spec:          simulatorSpec{device: "iPhone 8", osVersion: "latest"},
+			policy:        matchPolicyLatestWithDevice,
+			wantID:        "6F7A8B9C-0D1E-4F2A-3B4C-5D6E7F8A9B10",
+			wantOSVersion: "iOS 11.4",
+			wantLog: []string{
+				"- iPhone 8 on iOS 12.2: rejected, no such simulator",
+				"- iPhone 8 on iOS 12.1 (8B9C0D1E-2F3A-4B4C-5D6E-7F8A9B0C1D12): rejected, unavailable, device type profile not found",
+			},
+		},
+		{
+			name:            "constraint with fallback device",
+			fixture:         "list_xcode10.2_runtimes.json",
+			spec:            simulatorSpec{device: "iPad Pro (9.7-inch)", osVersion: ">= 12.0"},
+			policy:          matchPolicyLatestWithDevice,
+			wantID:          "0D1E2F3A-4B5C-4D6E-7F8A-9B0C1D2E3F14",
+			wantOSVersion:   "iOS 12.2",
+			fallbackDevices: []string{"iPhone 8", "iPhone XS"},
+			wantLog: []string{
+				"- iOS 11.4: rejected, does not satisfy >= 12.0",
+				"- iPad Pro (9.7-inch) on iOS 12.2: rejected, no such simulator",
+				"- iPad Pro (9.7-inch) on iOS 12.1: rejected, no such simulator",
+				"- iPhone 8 on iOS 12.1 (8B9C0D1E-2F3A-4B4C-5D6E-7F8A9B0C1D12): rejected, unavailable, device type profile not found",
+			},
+		},
+		{
+			name:            "device is preferred over a fallback on a newer runtime",
+			fixture:         "list_xcode10.2_runtimes.json",
+			spec:            simulatorSpec{device: "iPad Pro (9.7-inch)", osVersion: "latest"},
+			policy:          matchPolicyLatestWithDevice,
+			fallbackDevices: []string{"iPhone XS"},
+			wantID:          "7A8B9C0D-1E2F-4A3B-4C5D-6E7F8A9B0C11",
+			wantOSVersion:   "iOS 11.4",
+		},
+		{
+			name:            "no matching device",
+			fixture:         "list_xcode10.2_runtimes.json",
+			spec:            simulatorSpec{device: "iPad Pro (9.7-inch)", osVersion: "~> 12.1"},
+			policy:          matchPolicyLatestWithDevice,
+			fallbackDevices: []string{"iPhone 8"},
+			wantErr:         true,
+		},
+		{
+			name:    "unavailable runtime",
+			fixture: "list_xcode10.1.json",
+			spec:    simulatorSpec{device: "iPhone 8", osVersion: "iOS 12.0"},
+			policy:  matchPolicyLatestWithDevice,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inventory := inventoryFixture(t, tt.fixture)
+
+			var info simulator.InfoModel
+			var osVersion string
+			var err error
+			out := captureLog(func() {
+				info, osVersion, err = selectSimulator(inventory, tt.spec, tt.policy, tt.fallbackDevices)
+			})
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectSimulator() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if info.ID != tt.wantID || osVersion != tt.wantOSVersion {
+				t.Errorf("selectSimulator() = %s on %s, want %s on %s", info.ID, osVersion, tt.wantID, tt.wantOSVersion)
+			}
+
+			for _, line := range tt.wantLog {
+				if !strings.Contains(out, line) {
+					t.Errorf("log does not contain (%s):\n%s", line, out)
+				}
+			}
+		})
+	}
+}
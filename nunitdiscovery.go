@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-tools/go-xamarin/analyzers/solution"
+	version "github.com/hashicorp/go-version"
+)
+
+const nunitConsoleRunnerPackage = "NUnit.ConsoleRunner"
+
+type packagesConfig struct {
+	Packages []struct {
+		ID      string `xml:"id,attr"`
+		Version string `xml:"version,attr"`
+	} `xml:"package"`
+}
+
+type projectPackageReferences struct {
+	ItemGroups []struct {
+		PackageReferences []struct {
+			Include        string `xml:"Include,attr"`
+			Version        string `xml:"Version,attr"`
+			VersionElement string `xml:"Version"`
+		} `xml:"PackageReference"`
+	} `xml:"ItemGroup"`
+}
+
+// referencedPackageVersions returns the versions of the package referenced by the project,
+// either in the packages.config next to the project or as a PackageReference.
+func referencedPackageVersions(projectPth, packageID string) ([]string, error) {
+	versions := []string{}
+
+	packagesConfigPth := filepath.Join(filepath.Dir(projectPth), "packages.config")
+	if exist, err := pathutil.IsPathExists(packagesConfigPth); err != nil {
+		return nil, err
+	} else if exist {
+		content, err := fileutil.ReadBytesFromFile(packagesConfigPth)
+		if err != nil {
+			return nil, err
+		}
+
+		var config packagesConfig
+		if err := xml.Unmarshal(content, &config); err != nil {
+			return nil, fmt.Errorf("Failed to parse (%s), error: %s", packagesConfigPth, err)
+		}
+
+		for _, pkg := range config.Packages {
+			if strings.EqualFold(pkg.ID, packageID) {
+				versions = append(versions, pkg.Version)
+			}
+		}
+	}
+
+	content, err := fileutil.ReadBytesFromFile(projectPth)
+	if err != nil {
+		return nil, err
+	}
+
+	var references projectPackageReferences
+	if err := xml.Unmarshal(content, &references); err != nil {
+		return nil, fmt.Errorf("Failed to parse (%s), error: %s", projectPth, err)
+	}
+
+	for _, itemGroup := range references.ItemGroups {
+		for _, reference := range itemGroup.PackageReferences {
+			if !strings.EqualFold(reference.Include, packageID) {
+				continue
+			}
+
+			v := reference.Version
+			if v == "" {
+				v = reference.VersionElement
+			}
+			versions = append(versions, strings.TrimSpace(v))
+		}
+	}
+
+	return versions, nil
+}
+
+// nunitConsoleInstallation is a nunit3-console.exe restored from the NUnit.ConsoleRunner package.
+type nunitConsoleInstallation struct {
+	pth     string
+	version *version.Version
+}
+
+// nugetGlobalPackagesDir returns the global NuGet package cache.
+func nugetGlobalPackagesDir() string {
+	if dir := os.Getenv("NUGET_PACKAGES"); dir != "" {
+		return dir
+	}
+	return filepath.Join(pathutil.UserHomeDir(), ".nuget", "packages")
+}
+
+// findNunitConsoleInstallations lists the nunit3-console.exe files in the solution's packages dir
+// (packages/NUnit.ConsoleRunner.<version>) and in the global NuGet package cache (nunit.consolerunner/<version>).
+func findNunitConsoleInstallations(solutionDir string) []nunitConsoleInstallation {
+	installations := []nunitConsoleInstallation{}
+
+	add := func(versionDir, versionStr string) {
+		pth := filepath.Join(versionDir, "tools", "nunit3-console.exe")
+		if exist, err := pathutil.IsPathExists(pth); err != nil || !exist {
+			return
+		}
+
+		v, err := version.NewVersion(versionStr)
+		if err != nil {
+			log.Warnf("Failed to parse version of (%s), error: %s", pth, err)
+			return
+		}
+		installations = append(installations, nunitConsoleInstallation{pth: pth, version: v})
+	}
+
+	packagesDir := filepath.Join(solutionDir, "packages")
+	if fileInfos, err := ioutil.ReadDir(packagesDir); err == nil {
+		prefix := strings.ToLower(nunitConsoleRunnerPackage + ".")
+		for _, info := range fileInfos {
+			if info.IsDir() && strings.HasPrefix(strings.ToLower(info.Name()), prefix) {
+				add(filepath.Join(packagesDir, info.Name()), info.Name()[len(prefix):])
+			}
+		}
+	}
+
+	globalPackageDir := filepath.Join(nugetGlobalPackagesDir(), strings.ToLower(nunitConsoleRunnerPackage))
+	if fileInfos, err := ioutil.ReadDir(globalPackageDir); err == nil {
+		for _, info := range fileInfos {
+			if info.IsDir() {
+				add(filepath.Join(globalPackageDir, info.Name()), info.Name())
+			}
+		}
+	}
+
+	sort.SliceStable(installations, func(i, j int) bool {
+		return installations[i].version.GreaterThan(installations[j].version)
+	})
+
+	return installations
+}
+
+// isExactPackageVersion reports if the referenced version is an exact version (3.9.0, [3.9.0]), not a range or floating version.
+func isExactPackageVersion(v string) bool {
+	return v != "" && !strings.ContainsAny(strings.Trim(v, "[]"), "*,()")
+}
+
+// discoverNunitConsolePath returns the newest nunit3-console.exe restored from the NUnit.ConsoleRunner package,
+// matching the versions referenced by the solution's projects. If no project references an exact version,
+// the newest restored one is returned.
+func discoverNunitConsolePath(solutionPth string) (string, error) {
+	sln, err := solution.New(solutionPth, true)
+	if err != nil {
+		return "", fmt.Errorf("Failed to analyze solution (%s), error: %s", solutionPth, err)
+	}
+
+	referencedVersions := map[string]bool{}
+	for _, proj := range sln.ProjectMap {
+		versions, err := referencedPackageVersions(proj.Pth, nunitConsoleRunnerPackage)
+		if err != nil {
+			log.Warnf("Failed to read package references of (%s), error: %s", proj.Pth, err)
+			continue
+		}
+
+		for _, v := range versions {
+			if !isExactPackageVersion(v) {
+				continue
+			}
+
+			parsed, err := version.NewVersion(strings.Trim(v, "[]"))
+			if err != nil {
+				continue
+			}
+			log.Printf("%s references %s %s", proj.Name, nunitConsoleRunnerPackage, parsed)
+			referencedVersions[parsed.String()] = true
+		}
+	}
+
+	installations := findNunitConsoleInstallations(filepath.Dir(solutionPth))
+	if len(installations) == 0 {
+		return "", fmt.Errorf("no restored %s package found", nunitConsoleRunnerPackage)
+	}
+
+	for _, installation := range installations {
+		if len(referencedVersions) > 0 && !referencedVersions[installation.version.String()] {
+			log.Printf("- %s: not referenced by the solution", installation.pth)
+			continue
+		}
+		return installation.pth, nil
+	}
+
+	return "", fmt.Errorf("none of the restored %s packages matches the referenced versions", nunitConsoleRunnerPackage)
+}
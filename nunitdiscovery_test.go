@@ -0,0 +1,241 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIsExactPackageVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{version: "3.9.0", want: true},
+		{version: "[3.9.0]", want: true},
+		{version: "3.10.0-beta1", want: true},
+		{version: "", want: false},
+		{version: "3.*", want: false},
+		{version: "[3.8,4.0)", want: false},
+		{version: "(3.8,)", want: false},
+		{version: "[3.8,]", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isExactPackageVersion(tt.version); got != tt.want {
+			t.Errorf("isExactPackageVersion(%q) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
+
+// writeTestFile writes the content to the path relative to dir, creating the parent dirs.
+func writeTestFile(t *testing.T, dir, pth, content string) string {
+	pth = filepath.Join(dir, pth)
+	if err := os.MkdirAll(filepath.Dir(pth), 0755); err != nil {
+		t.Fatalf("Failed to create dir, error: %s", err)
+	}
+	if err := ioutil.WriteFile(pth, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write (%s), error: %s", pth, err)
+	}
+	return pth
+}
+
+func testProject(packageReferences string) string {
+	return `<?xml version="1.0" encoding="utf-8"?>
+<Project ToolsVersion="15.0" xmlns="http://schemas.microsoft.com/developer/msbuild/2003">
+  <ItemGroup>
+    <Reference Include="System" />
+  </ItemGroup>
+  <ItemGroup>
+` + packageReferences + `
+  </ItemGroup>
+</Project>
+`
+}
+
+func TestReferencedPackageVersions(t *testing.T) {
+	tests := []struct {
+		name              string
+		packagesConfig    string
+		packageReferences string
+		want              []string
+		wantErr           bool
+	}{
+		{
+			name: "packages.config",
+			packagesConfig: `<?xml version="1.0" encoding="utf-8"?>
+<packages>
+  <package id="NUnit" version="3.11.0" targetFramework="net461" />
+  <package id="NUnit.ConsoleRunner" version="3.9.0" targetFramework="net461" />
+</packages>`,
+			want: []string{"3.9.0"},
+		},
+		{
+			name:              "package reference attribute",
+			packageReferences: `<PackageReference Include="nunit.consolerunner" Version="[3.9.0]" />`,
+			want:              []string{"[3.9.0]"},
+		},
+		{
+			name: "package reference element",
+			packageReferences: `<PackageReference Include="NUnit.ConsoleRunner">
+      <Version> 3.10.0 </Version>
+    </PackageReference>
+    <PackageReference Include="Xamarin.UITest" Version="2.2.7" />`,
+			want: []string{"3.10.0"},
+		},
+		{
+			name:              "packages.config and package reference",
+			packagesConfig:    `<packages><package id="NUnit.ConsoleRunner" version="3.8.0" /></packages>`,
+			packageReferences: `<PackageReference Include="NUnit.ConsoleRunner" Version="3.*" />`,
+			want:              []string{"3.8.0", "3.*"},
+		},
+		{
+			name: "not referenced",
+			want: []string{},
+		},
+		{
+			name:           "invalid packages.config",
+			packagesConfig: `<packages><package id="NUnit.ConsoleRunner"`,
+			wantErr:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, err := ioutil.TempDir("", "package-references")
+			if err != nil {
+				t.Fatalf("Failed to create tmp dir, error: %s", err)
+			}
+			defer func() {
+				if err := os.RemoveAll(tmpDir); err != nil {
+					t.Errorf("Failed to remove tmp dir, error: %s", err)
+				}
+			}()
+
+			projectPth := writeTestFile(t, tmpDir, "MyApp.UITests/MyApp.UITests.csproj", testProject(tt.packageReferences))
+			if tt.packagesConfig != "" {
+				writeTestFile(t, tmpDir, "MyApp.UITests/packages.config", tt.packagesConfig)
+			}
+
+			got, err := referencedPackageVersions(projectPth, nunitConsoleRunnerPackage)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("referencedPackageVersions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("referencedPackageVersions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := referencedPackageVersions("/not/existing/MyApp.UITests.csproj", nunitConsoleRunnerPackage); err == nil {
+		t.Errorf("referencedPackageVersions() expected error for a missing project")
+	}
+}
+
+func TestDiscoverNunitConsolePath(t *testing.T) {
+	const testSolution = `Microsoft Visual Studio Solution File, Format Version 12.00
+Project("{FAE04EC0-301F-11D3-BF4B-00C04F79EFBC}") = "MyApp.UITests", "MyApp.UITests\MyApp.UITests.csproj", "{A1B2C3D4-0000-0000-0000-000000000001}"
+EndProject
+Project("{FAE04EC0-301F-11D3-BF4B-00C04F79EFBC}") = "MyApp.SmokeTests", "MyApp.SmokeTests\MyApp.SmokeTests.csproj", "{A1B2C3D4-0000-0000-0000-000000000002}"
+EndProject
+`
+
+	tests := []struct {
+		name string
+		// package reference of each test project
+		uiTestsReference    string
+		smokeTestsReference string
+		// restored versions in the solution's packages dir and in the global package cache
+		solutionPackages []string
+		globalPackages   []string
+		want             string
+		wantErr          bool
+	}{
+		{
+			name:                "newest matching the exact reference",
+			uiTestsReference:    `<PackageReference Include="NUnit.ConsoleRunner" Version="3.8.0" />`,
+			smokeTestsReference: `<PackageReference Include="NUnit.ConsoleRunner" Version="[3.0,4.0)" />`,
+			solutionPackages:    []string{"3.8.0", "3.9.0"},
+			globalPackages:      []string{"3.10.0"},
+			want:                "solution/packages/NUnit.ConsoleRunner.3.8.0/tools/nunit3-console.exe",
+		},
+		{
+			name:                "newest of the exact references, from the global packages",
+			uiTestsReference:    `<PackageReference Include="NUnit.ConsoleRunner"><Version>[3.10.0]</Version></PackageReference>`,
+			smokeTestsReference: `<PackageReference Include="NUnit.ConsoleRunner" Version="3.8.0" />`,
+			solutionPackages:    []string{"3.8.0", "3.9.0"},
+			globalPackages:      []string{"3.10.0", "3.11.1"},
+			want:                "nuget/nunit.consolerunner/3.10.0/tools/nunit3-console.exe",
+		},
+		{
+			name:                "no exact reference",
+			smokeTestsReference: `<PackageReference Include="NUnit.ConsoleRunner" Version="3.*" />`,
+			solutionPackages:    []string{"3.9.0"},
+			globalPackages:      []string{"3.8.0", "3.10.0"},
+			want:                "nuget/nunit.consolerunner/3.10.0/tools/nunit3-console.exe",
+		},
+		{
+			name:             "referenced version is not restored",
+			uiTestsReference: `<PackageReference Include="NUnit.ConsoleRunner" Version="3.7.0" />`,
+			solutionPackages: []string{"3.8.0"},
+			globalPackages:   []string{"3.10.0"},
+			wantErr:          true,
+		},
+		{
+			name:             "nothing restored",
+			uiTestsReference: `<PackageReference Include="NUnit.ConsoleRunner" Version="3.8.0" />`,
+			wantErr:          true,
+		},
+	}
+
+	originalNugetPackages, nugetPackagesSet := os.LookupEnv("NUGET_PACKAGES")
+	defer func() {
+		if nugetPackagesSet {
+			if err := os.Setenv("NUGET_PACKAGES", originalNugetPackages); err != nil {
+				t.Errorf("Failed to restore NUGET_PACKAGES, error: %s", err)
+			}
+		} else if err := os.Unsetenv("NUGET_PACKAGES"); err != nil {
+			t.Errorf("Failed to unset NUGET_PACKAGES, error: %s", err)
+		}
+	}()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, err := ioutil.TempDir("", "nunit-discovery")
+			if err != nil {
+				t.Fatalf("Failed to create tmp dir, error: %s", err)
+			}
+			defer func() {
+				if err := os.RemoveAll(tmpDir); err != nil {
+					t.Errorf("Failed to remove tmp dir, error: %s", err)
+				}
+			}()
+
+			solutionPth := writeTestFile(t, tmpDir, "solution/MyApp.sln", testSolution)
+			writeTestFile(t, tmpDir, "solution/MyApp.UITests/MyApp.UITests.csproj", testProject(tt.uiTestsReference))
+			writeTestFile(t, tmpDir, "solution/MyApp.SmokeTests/MyApp.SmokeTests.csproj", testProject(tt.smokeTestsReference))
+			for _, v := range tt.solutionPackages {
+				writeTestFile(t, tmpDir, "solution/packages/NUnit.ConsoleRunner."+v+"/tools/nunit3-console.exe", "")
+			}
+			for _, v := range tt.globalPackages {
+				writeTestFile(t, tmpDir, "nuget/nunit.consolerunner/"+v+"/tools/nunit3-console.exe", "")
+			}
+			// a package without the console is skipped
+			writeTestFile(t, tmpDir, "nuget/nunit.consolerunner/4.0.0/nunit.consolerunner.nuspec", "")
+
+			if err := os.Setenv("NUGET_PACKAGES", filepath.Join(tmpDir, "nuget")); err != nil {
+				t.Fatalf("Failed to set NUGET_PACKAGES, error: %s", err)
+			}
+
+			got, err := discoverNunitConsolePath(solutionPth)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("discoverNunitConsolePath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != filepath.Join(tmpDir, tt.want) {
+				t.Errorf("discoverNunitConsolePath() = %s, want %s", got, filepath.Join(tmpDir, tt.want))
+			}
+		})
+	}
+}
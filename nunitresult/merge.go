@@ -0,0 +1,181 @@
+package nunitresult
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+)
+
+// MergeRetries replaces the test cases of the original run with their results from the retry runs,
+// the latest result of a test case wins. Test cases which failed originally but passed in a retry
+// are returned as flaky tests, in the order of the original run.
+func MergeRetries(original TestRun, retries ...TestRun) (TestRun, []string) {
+	latestResults := map[string]TestCase{}
+	for _, retry := range retries {
+		for _, testCase := range retry.TestCases() {
+			latestResults[testCase.FullName] = testCase
+		}
+		original.Duration += retry.Duration
+		if retry.EndTime != "" {
+			original.EndTime = retry.EndTime
+		}
+	}
+
+	flakyTests := []string{}
+	for _, testCase := range original.TestCases() {
+		retried, ok := latestResults[testCase.FullName]
+		if ok && testCase.Result == ResultFailed && retried.Result == ResultPassed {
+			flakyTests = append(flakyTests, testCase.FullName)
+		}
+	}
+
+	merged := original
+	merged.TestSuites = make([]TestSuite, len(original.TestSuites))
+	for i, testSuite := range original.TestSuites {
+		merged.TestSuites[i] = mergeTestSuite(testSuite, latestResults)
+	}
+	merged.updateCounts()
+
+	return merged, flakyTests
+}
+
+// Combine merges the test runs of different test assemblies (or apps, simulators) into a single test run:
+// the test suites of every run are listed under the combined run, the counts and durations are summed.
+func Combine(testRuns ...TestRun) TestRun {
+	combined := TestRun{TestSuites: []TestSuite{}}
+
+	for i, testRun := range testRuns {
+		if i == 0 {
+			combined.ID = testRun.ID
+		}
+
+		combined.TestCaseCount += testRun.TestCaseCount
+		combined.Total += testRun.Total
+		combined.Passed += testRun.Passed
+		combined.Failed += testRun.Failed
+		combined.Inconclusive += testRun.Inconclusive
+		combined.Skipped += testRun.Skipped
+		combined.Asserts += testRun.Asserts
+		combined.Duration += testRun.Duration
+
+		// the times are in the same sortable format (2018-05-14 12:34:56Z)
+		if testRun.StartTime != "" && (combined.StartTime == "" || testRun.StartTime < combined.StartTime) {
+			combined.StartTime = testRun.StartTime
+		}
+		if testRun.EndTime > combined.EndTime {
+			combined.EndTime = testRun.EndTime
+		}
+
+		switch {
+		case testRun.Result == ResultFailed:
+			combined.Result = ResultFailed
+		case combined.Result == "" || combined.Result != ResultFailed && testRun.Result == ResultPassed:
+			combined.Result = testRun.Result
+		}
+
+		combined.TestSuites = append(combined.TestSuites, testRun.TestSuites...)
+	}
+
+	return combined
+}
+
+func mergeTestSuite(testSuite TestSuite, latestResults map[string]TestCase) TestSuite {
+	merged := testSuite
+
+	merged.TestCases = make([]TestCase, len(testSuite.TestCases))
+	for i, testCase := range testSuite.TestCases {
+		if retried, ok := latestResults[testCase.FullName]; ok {
+			testCase = retried
+		}
+		merged.TestCases[i] = testCase
+	}
+
+	merged.TestSuites = make([]TestSuite, len(testSuite.TestSuites))
+	for i, childSuite := range testSuite.TestSuites {
+		merged.TestSuites[i] = mergeTestSuite(childSuite, latestResults)
+	}
+
+	return merged
+}
+
+func (testRun *TestRun) updateCounts() {
+	testRun.Total, testRun.Passed, testRun.Failed, testRun.Inconclusive, testRun.Skipped = 0, 0, 0, 0, 0
+	suiteFailed := false
+	for i := range testRun.TestSuites {
+		testSuite := &testRun.TestSuites[i]
+		testSuite.updateCounts()
+
+		testRun.Total += testSuite.Total
+		testRun.Passed += testSuite.Passed
+		testRun.Failed += testSuite.Failed
+		testRun.Inconclusive += testSuite.Inconclusive
+		testRun.Skipped += testSuite.Skipped
+		suiteFailed = suiteFailed || testSuite.Result == ResultFailed
+	}
+
+	if testRun.Failed > 0 || suiteFailed {
+		testRun.Result = ResultFailed
+	} else if testRun.Result == ResultFailed {
+		testRun.Result = ResultPassed
+	}
+}
+
+func (testSuite *TestSuite) updateCounts() {
+	testSuite.Total, testSuite.Passed, testSuite.Failed, testSuite.Warnings, testSuite.Inconclusive, testSuite.Skipped = 0, 0, 0, 0, 0, 0
+
+	for _, testCase := range testSuite.TestCases {
+		testSuite.Total++
+		switch testCase.Result {
+		case ResultPassed:
+			testSuite.Passed++
+		case ResultFailed:
+			testSuite.Failed++
+		case ResultWarning:
+			testSuite.Warnings++
+		case ResultInconclusive:
+			testSuite.Inconclusive++
+		case ResultSkipped:
+			testSuite.Skipped++
+		}
+	}
+
+	childFailed := false
+	for i := range testSuite.TestSuites {
+		childSuite := &testSuite.TestSuites[i]
+		childSuite.updateCounts()
+		childFailed = childFailed || childSuite.Result == ResultFailed
+
+		testSuite.Total += childSuite.Total
+		testSuite.Passed += childSuite.Passed
+		testSuite.Failed += childSuite.Failed
+		testSuite.Warnings += childSuite.Warnings
+		testSuite.Inconclusive += childSuite.Inconclusive
+		testSuite.Skipped += childSuite.Skipped
+	}
+
+	// A failure reported by the suite itself (SetUp, TearDown) is not affected by the retries,
+	// a failure propagated from its children is, unless a child suite keeps its own failure.
+	if testSuite.Failed > 0 || childFailed {
+		testSuite.Result = ResultFailed
+	} else if testSuite.Result == ResultFailed && (testSuite.Failure == nil || testSuite.Site == "Child") {
+		testSuite.Result = ResultPassed
+		testSuite.Label = ""
+		testSuite.Site = ""
+		testSuite.Failure = nil
+	}
+}
+
+// WriteToFile ...
+func (testRun TestRun) WriteToFile(pth string) error {
+	content, err := xml.MarshalIndent(testRun, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to marshal nunit result, error: %s", err)
+	}
+
+	if err := fileutil.WriteStringToFile(pth, xml.Header+string(content)); err != nil {
+		return fmt.Errorf("Failed to write nunit result to (%s), error: %s", pth, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package nunitresult
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testCase(name, result string) TestCase {
+	return TestCase{Name: name, FullName: "Tests." + name, Result: result}
+}
+
+func testRunWithCases(testCases ...TestCase) TestRun {
+	return TestRun{
+		Result: ResultFailed,
+		TestSuites: []TestSuite{
+			{
+				Type:   "Assembly",
+				Name:   "Tests.dll",
+				Result: ResultFailed,
+				Site:   "Child",
+				TestSuites: []TestSuite{
+					{Type: "TestFixture", Name: "Tests", Result: ResultFailed, Site: "Child", TestCases: testCases},
+				},
+			},
+		},
+	}
+}
+
+func TestMergeRetries(t *testing.T) {
+	setUpFailure := testRunWithCases(testCase("A", ResultFailed), testCase("B", ResultPassed))
+	fixture := &setUpFailure.TestSuites[0].TestSuites[0]
+	fixture.Site = "SetUp"
+	fixture.Failure = &Failure{Message: "OneTimeSetUp: app crashed"}
+
+	tests := []struct {
+		name       string
+		original   TestRun
+		retries    []TestRun
+		wantResult string
+		wantFailed int
+		wantPassed int
+		wantFlaky  []string
+	}{
+		{
+			name:       "fail then pass",
+			original:   testRunWithCases(testCase("A", ResultFailed), testCase("B", ResultPassed)),
+			retries:    []TestRun{testRunWithCases(testCase("A", ResultPassed))},
+			wantResult: ResultPassed,
+			wantFailed: 0,
+			wantPassed: 2,
+			wantFlaky:  []string{"Tests.A"},
+		},
+		{
+			name:       "fail then fail",
+			original:   testRunWithCases(testCase("A", ResultFailed), testCase("B", ResultPassed)),
+			retries:    []TestRun{testRunWithCases(testCase("A", ResultFailed))},
+			wantResult: ResultFailed,
+			wantFailed: 1,
+			wantPassed: 1,
+			wantFlaky:  []string{},
+		},
+		{
+			name:     "fail, fail then pass",
+			original: testRunWithCases(testCase("A", ResultFailed), testCase("B", ResultFailed)),
+			retries: []TestRun{
+				testRunWithCases(testCase("A", ResultFailed), testCase("B", ResultPassed)),
+				testRunWithCases(testCase("A", ResultPassed)),
+			},
+			wantResult: ResultPassed,
+			wantFailed: 0,
+			wantPassed: 2,
+			wantFlaky:  []string{"Tests.A", "Tests.B"},
+		},
+		{
+			name:       "suite level setup failure is kept",
+			original:   setUpFailure,
+			retries:    []TestRun{testRunWithCases(testCase("A", ResultPassed))},
+			wantResult: ResultFailed,
+			wantFailed: 0,
+			wantPassed: 2,
+			wantFlaky:  []string{"Tests.A"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, flakyTests := MergeRetries(tt.original, tt.retries...)
+
+			if merged.Result != tt.wantResult {
+				t.Errorf("Result = %s, want %s", merged.Result, tt.wantResult)
+			}
+			if merged.Total != 2 || merged.Failed != tt.wantFailed || merged.Passed != tt.wantPassed {
+				t.Errorf("counts = total: %d, failed: %d, passed: %d, want total: 2, failed: %d, passed: %d", merged.Total, merged.Failed, merged.Passed, tt.wantFailed, tt.wantPassed)
+			}
+			if !reflect.DeepEqual(flakyTests, tt.wantFlaky) {
+				t.Errorf("flaky tests = %v, want %v", flakyTests, tt.wantFlaky)
+			}
+		})
+	}
+
+	t.Run("setup failure keeps the suite failure", func(t *testing.T) {
+		merged, _ := MergeRetries(setUpFailure, testRunWithCases(testCase("A", ResultPassed)))
+
+		assembly := merged.TestSuites[0]
+		if assembly.Result != ResultFailed {
+			t.Errorf("assembly Result = %s, want %s", assembly.Result, ResultFailed)
+		}
+		fixture := assembly.TestSuites[0]
+		if fixture.Result != ResultFailed || fixture.Failure == nil || fixture.Site != "SetUp" {
+			t.Errorf("fixture = %+v, want the SetUp failure", fixture)
+		}
+	})
+
+	t.Run("original run is not modified", func(t *testing.T) {
+		original := testRunWithCases(testCase("A", ResultFailed))
+		MergeRetries(original, testRunWithCases(testCase("A", ResultPassed)))
+
+		if got := original.TestSuites[0].TestSuites[0].TestCases[0].Result; got != ResultFailed {
+			t.Errorf("original test case Result = %s, want %s", got, ResultFailed)
+		}
+	})
+}
+
+func TestUpdateCounts(t *testing.T) {
+	testRun := testRunWithCases(
+		testCase("A", ResultPassed),
+		testCase("B", ResultPassed),
+		testCase("C", ResultInconclusive),
+		testCase("D", ResultSkipped),
+		testCase("E", ResultWarning),
+	)
+	// stale counts of a previous run
+	testRun.Total, testRun.Failed = 10, 3
+	testRun.TestSuites[0].Total, testRun.TestSuites[0].Failed = 10, 3
+
+	testRun.updateCounts()
+
+	if testRun.Total != 5 || testRun.Passed != 2 || testRun.Failed != 0 || testRun.Inconclusive != 1 || testRun.Skipped != 1 {
+		t.Errorf("test run counts = %+v", testRun)
+	}
+	if testRun.Result != ResultPassed {
+		t.Errorf("test run Result = %s, want %s", testRun.Result, ResultPassed)
+	}
+
+	for _, testSuite := range []TestSuite{testRun.TestSuites[0], testRun.TestSuites[0].TestSuites[0]} {
+		if testSuite.Total != 5 || testSuite.Passed != 2 || testSuite.Warnings != 1 || testSuite.Failed != 0 {
+			t.Errorf("%s counts = %+v", testSuite.Name, testSuite)
+		}
+		if testSuite.Result != ResultPassed || testSuite.Site != "" {
+			t.Errorf("%s Result = %s, Site = %s, want %s and no site", testSuite.Name, testSuite.Result, testSuite.Site, ResultPassed)
+		}
+	}
+}
+
+func TestCombine(t *testing.T) {
+	first := testRunWithCases(testCase("A", ResultPassed))
+	first.Result, first.Total, first.Passed, first.Duration = ResultPassed, 1, 1, 10
+	first.StartTime, first.EndTime = "2018-05-14 10:00:00Z", "2018-05-14 10:00:10Z"
+
+	second := testRunWithCases(testCase("B", ResultFailed))
+	second.Result, second.Total, second.Failed, second.Duration = ResultFailed, 1, 1, 5.5
+	second.StartTime, second.EndTime = "2018-05-14 09:59:00Z", "2018-05-14 10:00:05Z"
+
+	combined := Combine(first, second)
+
+	if combined.Result != ResultFailed || combined.Total != 2 || combined.Passed != 1 || combined.Failed != 1 || combined.Duration != 15.5 {
+		t.Errorf("combined = %+v", combined)
+	}
+	if combined.StartTime != second.StartTime || combined.EndTime != first.EndTime {
+		t.Errorf("combined times = %s - %s", combined.StartTime, combined.EndTime)
+	}
+	if len(combined.TestSuites) != 2 || len(combined.TestCases()) != 2 {
+		t.Errorf("combined test suites = %+v", combined.TestSuites)
+	}
+
+	if empty := Combine(); empty.Result != "" || len(empty.TestSuites) != 0 {
+		t.Errorf("Combine() = %+v", empty)
+	}
+}
@@ -0,0 +1,184 @@
+package nunitresult
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+)
+
+// Result values of the test-run, test-suite and test-case elements.
+const (
+	ResultPassed       = "Passed"
+	ResultFailed       = "Failed"
+	ResultInconclusive = "Inconclusive"
+	ResultSkipped      = "Skipped"
+	ResultWarning      = "Warning"
+)
+
+// TestRun is the root element of the nunit 3 result file.
+type TestRun struct {
+	XMLName xml.Name `xml:"test-run"`
+
+	ID            string  `xml:"id,attr,omitempty"`
+	TestCaseCount int     `xml:"testcasecount,attr"`
+	Result        string  `xml:"result,attr,omitempty"`
+	Total         int     `xml:"total,attr"`
+	Passed        int     `xml:"passed,attr"`
+	Failed        int     `xml:"failed,attr"`
+	Inconclusive  int     `xml:"inconclusive,attr"`
+	Skipped       int     `xml:"skipped,attr"`
+	Asserts       int     `xml:"asserts,attr"`
+	StartTime     string  `xml:"start-time,attr,omitempty"`
+	EndTime       string  `xml:"end-time,attr,omitempty"`
+	Duration      float64 `xml:"duration,attr"`
+
+	CommandLine string      `xml:"command-line,omitempty"`
+	TestSuites  []TestSuite `xml:"test-suite"`
+}
+
+// TestSuite ...
+type TestSuite struct {
+	Type          string  `xml:"type,attr,omitempty"`
+	ID            string  `xml:"id,attr,omitempty"`
+	Name          string  `xml:"name,attr,omitempty"`
+	FullName      string  `xml:"fullname,attr,omitempty"`
+	ClassName     string  `xml:"classname,attr,omitempty"`
+	RunState      string  `xml:"runstate,attr,omitempty"`
+	TestCaseCount int     `xml:"testcasecount,attr"`
+	Result        string  `xml:"result,attr,omitempty"`
+	Label         string  `xml:"label,attr,omitempty"`
+	Site          string  `xml:"site,attr,omitempty"`
+	StartTime     string  `xml:"start-time,attr,omitempty"`
+	EndTime       string  `xml:"end-time,attr,omitempty"`
+	Duration      float64 `xml:"duration,attr"`
+	Total         int     `xml:"total,attr"`
+	Passed        int     `xml:"passed,attr"`
+	Failed        int     `xml:"failed,attr"`
+	Warnings      int     `xml:"warnings,attr"`
+	Inconclusive  int     `xml:"inconclusive,attr"`
+	Skipped       int     `xml:"skipped,attr"`
+	Asserts       int     `xml:"asserts,attr"`
+
+	Properties  *Properties  `xml:"properties"`
+	Failure     *Failure     `xml:"failure"`
+	Reason      *Reason      `xml:"reason"`
+	Output      string       `xml:"output,omitempty"`
+	Attachments *Attachments `xml:"attachments"`
+	TestSuites  []TestSuite  `xml:"test-suite"`
+	TestCases   []TestCase   `xml:"test-case"`
+}
+
+// TestCase ...
+type TestCase struct {
+	ID         string  `xml:"id,attr,omitempty"`
+	Name       string  `xml:"name,attr,omitempty"`
+	FullName   string  `xml:"fullname,attr,omitempty"`
+	MethodName string  `xml:"methodname,attr,omitempty"`
+	ClassName  string  `xml:"classname,attr,omitempty"`
+	RunState   string  `xml:"runstate,attr,omitempty"`
+	Result     string  `xml:"result,attr,omitempty"`
+	Label      string  `xml:"label,attr,omitempty"`
+	Site       string  `xml:"site,attr,omitempty"`
+	StartTime  string  `xml:"start-time,attr,omitempty"`
+	EndTime    string  `xml:"end-time,attr,omitempty"`
+	Duration   float64 `xml:"duration,attr"`
+	Asserts    int     `xml:"asserts,attr"`
+
+	Properties  *Properties  `xml:"properties"`
+	Failure     *Failure     `xml:"failure"`
+	Reason      *Reason      `xml:"reason"`
+	Output      string       `xml:"output,omitempty"`
+	Attachments *Attachments `xml:"attachments"`
+}
+
+// Properties ...
+type Properties struct {
+	Items []Property `xml:"property"`
+}
+
+// Property ...
+type Property struct {
+	Name  string `xml:"name,attr,omitempty"`
+	Value string `xml:"value,attr,omitempty"`
+}
+
+// Failure ...
+type Failure struct {
+	Message    string `xml:"message"`
+	StackTrace string `xml:"stack-trace"`
+}
+
+// Reason holds the message of a skipped or ignored test.
+type Reason struct {
+	Message string `xml:"message"`
+}
+
+// Attachments ...
+type Attachments struct {
+	Items []Attachment `xml:"attachment"`
+}
+
+// Attachment ...
+type Attachment struct {
+	FilePath    string `xml:"filePath"`
+	Description string `xml:"description,omitempty"`
+}
+
+// Parse ...
+func Parse(content []byte) (TestRun, error) {
+	var testRun TestRun
+	if err := xml.Unmarshal(content, &testRun); err != nil {
+		return TestRun{}, fmt.Errorf("Failed to parse nunit result, error: %s", err)
+	}
+	return testRun, nil
+}
+
+// ParseFile ...
+func ParseFile(pth string) (TestRun, error) {
+	content, err := fileutil.ReadBytesFromFile(pth)
+	if err != nil {
+		return TestRun{}, fmt.Errorf("Failed to read file (%s), error: %s", pth, err)
+	}
+	return Parse(content)
+}
+
+// TestCases returns every test case of the run, in document order.
+func (testRun TestRun) TestCases() []TestCase {
+	testCases := []TestCase{}
+	for _, testSuite := range testRun.TestSuites {
+		testCases = append(testCases, testSuite.AllTestCases()...)
+	}
+	return testCases
+}
+
+// FailedTestCases ...
+func (testRun TestRun) FailedTestCases() []TestCase {
+	failedTestCases := []TestCase{}
+	for _, testCase := range testRun.TestCases() {
+		if testCase.Result == ResultFailed {
+			failedTestCases = append(failedTestCases, testCase)
+		}
+	}
+	return failedTestCases
+}
+
+// AllTestCases returns the test cases of the suite and of its nested suites.
+func (testSuite TestSuite) AllTestCases() []TestCase {
+	testCases := append([]TestCase{}, testSuite.TestCases...)
+	for _, childSuite := range testSuite.TestSuites {
+		testCases = append(testCases, childSuite.AllTestCases()...)
+	}
+	return testCases
+}
+
+// ParseTime parses the start-time and end-time attributes (2018-05-14 12:34:56Z, fractional seconds are optional).
+func ParseTime(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05Z", "2006-01-02T15:04:05Z"} {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s", value)
+}
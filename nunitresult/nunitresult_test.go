@@ -0,0 +1,113 @@
+package nunitresult
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFile(t *testing.T) {
+	testRun, err := ParseFile("testdata/TestResult.xml")
+	if err != nil {
+		t.Fatalf("ParseFile() error: %s", err)
+	}
+
+	if testRun.Result != ResultFailed || testRun.Total != 4 || testRun.Passed != 1 || testRun.Failed != 1 || testRun.Inconclusive != 1 || testRun.Skipped != 1 {
+		t.Errorf("test run = %+v", testRun)
+	}
+	if testRun.Duration != 90.123456 {
+		t.Errorf("Duration = %v", testRun.Duration)
+	}
+
+	testCases := testRun.TestCases()
+	wantNames := []string{
+		"CreditCardNumber_CorrectSize_DisplaySuccessScreen",
+		"CreditCardNumber_TooLong_DisplayErrorMessage",
+		"CreditCardNumber_Ignored",
+		"CreditCardNumber_Inconclusive",
+	}
+	if len(testCases) != len(wantNames) {
+		t.Fatalf("TestCases() = %d test cases, want %d", len(testCases), len(wantNames))
+	}
+	for i, name := range wantNames {
+		if testCases[i].Name != name {
+			t.Errorf("TestCases()[%d].Name = %s, want %s", i, testCases[i].Name, name)
+		}
+	}
+
+	passed := testCases[0]
+	if passed.Attachments == nil || len(passed.Attachments.Items) != 1 || passed.Attachments.Items[0].FilePath != "/builds/screenshot-1.png" || passed.Attachments.Items[0].Description != "Success screen" {
+		t.Errorf("Attachments = %+v", passed.Attachments)
+	}
+
+	failed := testRun.FailedTestCases()
+	if len(failed) != 1 {
+		t.Fatalf("FailedTestCases() = %d test cases, want 1", len(failed))
+	}
+	if failed[0].Failure == nil || failed[0].Failure.Message != "Timed out waiting for element..." || failed[0].Failure.StackTrace != "at Xamarin.UITest.Shared.WaitForHelper.WaitForAny" {
+		t.Errorf("Failure = %+v", failed[0].Failure)
+	}
+	if failed[0].Output != "Tapping on CardNumber" {
+		t.Errorf("Output = %s", failed[0].Output)
+	}
+
+	if skipped := testCases[2]; skipped.Reason == nil || skipped.Reason.Message != "Flaky on iOS 11" {
+		t.Errorf("Reason = %+v", skipped.Reason)
+	}
+
+	assembly := testRun.TestSuites[0]
+	if assembly.Properties == nil || len(assembly.Properties.Items) != 2 || assembly.Properties.Items[0].Name != "_PID" || assembly.Properties.Items[0].Value != "1234" {
+		t.Errorf("Properties = %+v", assembly.Properties)
+	}
+	if got := len(assembly.AllTestCases()); got != 4 {
+		t.Errorf("AllTestCases() = %d test cases, want 4", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Run("byte order mark", func(t *testing.T) {
+		testRun, err := Parse([]byte("\ufeff" + `<?xml version="1.0" encoding="utf-8"?><test-run total="0"></test-run>`))
+		if err != nil {
+			t.Fatalf("Parse() error: %s", err)
+		}
+		if len(testRun.TestCases()) != 0 {
+			t.Errorf("TestCases() = %+v", testRun.TestCases())
+		}
+	})
+
+	t.Run("nunit 2 result", func(t *testing.T) {
+		if _, err := Parse([]byte(`<?xml version="1.0" encoding="utf-8"?><test-results total="0"></test-results>`)); err == nil {
+			t.Errorf("Parse() expected error for a NUnit 2 result")
+		}
+	})
+
+	t.Run("invalid xml", func(t *testing.T) {
+		if _, err := Parse([]byte(`<test-run`)); err == nil {
+			t.Errorf("Parse() expected error for invalid xml")
+		}
+	})
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "2018-05-14 10:00:01Z", want: time.Date(2018, 5, 14, 10, 0, 1, 0, time.UTC)},
+		{value: "2018-05-14 10:01:30.123456Z", want: time.Date(2018, 5, 14, 10, 1, 30, 123456000, time.UTC)},
+		{value: "2018-05-14T10:00:01Z", want: time.Date(2018, 5, 14, 10, 0, 1, 0, time.UTC)},
+		{value: "", wantErr: true},
+		{value: "14/05/2018", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTime(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTime(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseTime(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/errorutil"
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/nunitresult"
+)
+
+// quarantineList holds the fully-qualified names of the tests whose failures should not fail the step.
+type quarantineList map[string]bool
+
+// readQuarantineList reads the test names from the given file, one per line,
+// empty lines and lines starting with # are ignored.
+func readQuarantineList(pth string) (quarantineList, error) {
+	quarantined := quarantineList{}
+	if pth == "" {
+		return quarantined, nil
+	}
+
+	content, err := fileutil.ReadStringFromFile(pth)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read quarantine list (%s), error: %s", pth, err)
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		quarantined[line] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to scan quarantine list (%s), error: %s", pth, err)
+	}
+
+	return quarantined, nil
+}
+
+// split returns the failed test cases of the run separated by whether they are quarantined.
+func (quarantined quarantineList) split(testRun nunitresult.TestRun) ([]nunitresult.TestCase, []nunitresult.TestCase) {
+	quarantinedTestCases := []nunitresult.TestCase{}
+	failedTestCases := []nunitresult.TestCase{}
+
+	for _, testCase := range testRun.FailedTestCases() {
+		if quarantined[testCase.FullName] {
+			quarantinedTestCases = append(quarantinedTestCases, testCase)
+		} else {
+			failedTestCases = append(failedTestCases, testCase)
+		}
+	}
+
+	return quarantinedTestCases, failedTestCases
+}
+
+// applyQuarantine clears the outcome's error if every failed test of the run is quarantined.
+func (outcome *testOutcome) applyQuarantine(quarantined quarantineList) {
+	if outcome.err == nil || outcome.testRun == nil || len(quarantined) == 0 {
+		return
+	}
+
+	quarantinedTestCases, failedTestCases := quarantined.split(*outcome.testRun)
+	for _, testCase := range quarantinedTestCases {
+		outcome.quarantinedTests = append(outcome.quarantinedTests, testCase.FullName)
+	}
+
+	if len(quarantinedTestCases) == 0 || len(failedTestCases) > 0 {
+		return
+	}
+
+	// nunit3-console exits with the number of failed tests, any other exit code means the run itself failed
+	// (its negative error codes wrap around, like -100 is reported as 156)
+	if exitCode, err := errorutil.CmdExitCodeFromError(outcome.err); err != nil || exitCode != len(quarantinedTestCases) {
+		return
+	}
+
+	fmt.Println()
+	log.Warnf("%d quarantined test(s) failed, ignoring their failures:", len(quarantinedTestCases))
+	for _, testCase := range quarantinedTestCases {
+		log.Warnf("- %s", testCase.FullName)
+	}
+
+	outcome.err = nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/nunitresult"
+)
+
+func TestReadQuarantineList(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "quarantine")
+	if err != nil {
+		t.Fatalf("Failed to create tmp dir, error: %s", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove tmp dir, error: %s", err)
+		}
+	}()
+
+	pth := filepath.Join(tmpDir, "quarantine.txt")
+	content := "# flaky on iOS 11\nTests.Login\n\n  Tests.Logout  \r\n#Tests.Disabled\n"
+	if err := ioutil.WriteFile(pth, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write quarantine list, error: %s", err)
+	}
+
+	quarantined, err := readQuarantineList(pth)
+	if err != nil {
+		t.Fatalf("readQuarantineList() error: %s", err)
+	}
+	if want := (quarantineList{"Tests.Login": true, "Tests.Logout": true}); !reflect.DeepEqual(quarantined, want) {
+		t.Errorf("readQuarantineList() = %v, want %v", quarantined, want)
+	}
+
+	if quarantined, err := readQuarantineList(""); err != nil || len(quarantined) != 0 {
+		t.Errorf("readQuarantineList(\"\") = %v, %v", quarantined, err)
+	}
+	if _, err := readQuarantineList(filepath.Join(tmpDir, "missing.txt")); err == nil {
+		t.Errorf("readQuarantineList() expected error for a missing file")
+	}
+}
+
+// exitError returns the error of a process exited with the given code.
+func exitError(t *testing.T, code int) error {
+	err := exec.Command("sh", "-c", fmt.Sprintf("exit %d", code)).Run()
+	if err == nil {
+		t.Fatalf("exit %d did not fail", code)
+	}
+	return err
+}
+
+func TestApplyQuarantine(t *testing.T) {
+	testRun := func(results ...string) *nunitresult.TestRun {
+		testCases := []nunitresult.TestCase{}
+		for i, result := range results {
+			name := fmt.Sprintf("Test%d", i)
+			testCases = append(testCases, nunitresult.TestCase{Name: name, FullName: "Tests." + name, Result: result})
+		}
+		return &nunitresult.TestRun{TestSuites: []nunitresult.TestSuite{{Name: "Tests", TestCases: testCases}}}
+	}
+
+	quarantined := quarantineList{"Tests.Test0": true, "Tests.Test1": true}
+
+	tests := []struct {
+		name            string
+		testRun         *nunitresult.TestRun
+		err             error
+		wantErr         bool
+		wantQuarantined []string
+	}{
+		{
+			name:            "every failure quarantined",
+			testRun:         testRun(nunitresult.ResultFailed, nunitresult.ResultFailed, nunitresult.ResultPassed),
+			err:             exitError(t, 2),
+			wantErr:         false,
+			wantQuarantined: []string{"Tests.Test0", "Tests.Test1"},
+		},
+		{
+			name:            "not quarantined failure",
+			testRun:         testRun(nunitresult.ResultFailed, nunitresult.ResultPassed, nunitresult.ResultFailed),
+			err:             exitError(t, 2),
+			wantErr:         true,
+			wantQuarantined: []string{"Tests.Test0"},
+		},
+		{
+			// mono exit(-100): unexpected error of the console
+			name:            "unexpected error",
+			testRun:         testRun(nunitresult.ResultFailed, nunitresult.ResultPassed),
+			err:             exitError(t, 156),
+			wantErr:         true,
+			wantQuarantined: []string{"Tests.Test0"},
+		},
+		{
+			// mono exit(-5): unload error, after the tests ran
+			name:            "unload error",
+			testRun:         testRun(nunitresult.ResultFailed, nunitresult.ResultPassed),
+			err:             exitError(t, 251),
+			wantErr:         true,
+			wantQuarantined: []string{"Tests.Test0"},
+		},
+		{
+			name:            "timeout",
+			testRun:         testRun(nunitresult.ResultFailed),
+			err:             fmt.Errorf("nunit console did not finish until the deadline"),
+			wantErr:         true,
+			wantQuarantined: []string{"Tests.Test0"},
+		},
+		{
+			name:            "no result",
+			err:             exitError(t, 1),
+			wantErr:         true,
+			wantQuarantined: []string{},
+		},
+		{
+			name:            "passed",
+			testRun:         testRun(nunitresult.ResultPassed),
+			wantErr:         false,
+			wantQuarantined: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcome := testOutcome{testRun: tt.testRun, err: tt.err, quarantinedTests: []string{}}
+			outcome.applyQuarantine(quarantined)
+
+			if (outcome.err != nil) != tt.wantErr {
+				t.Errorf("outcome error = %v, wantErr %v", outcome.err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(outcome.quarantinedTests, tt.wantQuarantined) {
+				t.Errorf("quarantined tests = %v, want %v", outcome.quarantinedTests, tt.wantQuarantined)
+			}
+		})
+	}
+}
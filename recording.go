@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/nunitresult"
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/simctl"
+)
+
+// screenshotInterval is the time between the screenshots taken during the test run.
+// The screenshot of a failed test is the last one taken before the test ended.
+const screenshotInterval = 2 * time.Second
+
+type timedScreenshot struct {
+	pth  string
+	time time.Time
+}
+
+// screenRecording records the screen of a simulator and takes screenshots periodically during a test run.
+type screenRecording struct {
+	udid string
+	dir  string
+
+	videoPth string
+	video    *simctl.BackgroundProcess
+
+	stop        chan struct{}
+	done        chan struct{}
+	screenshots []timedScreenshot
+	// lastScreenshot is the content of the last kept screenshot file
+	lastScreenshot []byte
+}
+
+// startScreenRecording starts recording the simulator screen into a temporary dir.
+func startScreenRecording(udid string) (*screenRecording, error) {
+	dir, err := pathutil.NormalizedOSTempDirPath("simulator-recording")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create tmp dir for the recording, error: %s", err)
+	}
+
+	videoPth := filepath.Join(dir, "recording.mp4")
+	video, err := simctl.RecordVideo(udid, videoPth)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to record simulator video, error: %s", err)
+	}
+
+	recording := &screenRecording{
+		udid:     udid,
+		dir:      dir,
+		videoPth: videoPth,
+		video:    video,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go recording.takeScreenshots()
+
+	return recording, nil
+}
+
+func (recording *screenRecording) takeScreenshots() {
+	defer close(recording.done)
+
+	ticker := time.NewTicker(screenshotInterval)
+	defer ticker.Stop()
+
+	for i := 0; ; i++ {
+		select {
+		case <-recording.stop:
+			return
+		case <-ticker.C:
+			pth := filepath.Join(recording.dir, fmt.Sprintf("screenshot_%d.png", i))
+			if err := simctl.Screenshot(recording.udid, pth); err != nil {
+				continue
+			}
+			recording.addScreenshot(pth, time.Now())
+		}
+	}
+}
+
+// addScreenshot keeps the screenshot taken at t. The screenshots are kept for the whole run,
+// but a screenshot identical to the previous one is removed, and the previous file is referenced instead.
+func (recording *screenRecording) addScreenshot(pth string, t time.Time) {
+	content, err := ioutil.ReadFile(pth)
+	if err != nil {
+		log.Warnf("Failed to read screenshot, error: %s", err)
+		return
+	}
+
+	if len(recording.screenshots) > 0 && bytes.Equal(content, recording.lastScreenshot) {
+		if err := os.Remove(pth); err != nil {
+			log.Warnf("Failed to remove screenshot, error: %s", err)
+		}
+		pth = recording.screenshots[len(recording.screenshots)-1].pth
+	} else {
+		recording.lastScreenshot = content
+	}
+
+	recording.screenshots = append(recording.screenshots, timedScreenshot{pth: pth, time: t})
+}
+
+// screenshotAt returns the last screenshot taken before t.
+func (recording *screenRecording) screenshotAt(t time.Time) (string, bool) {
+	for i := len(recording.screenshots) - 1; i >= 0; i-- {
+		if !recording.screenshots[i].time.After(t) {
+			return recording.screenshots[i].pth, true
+		}
+	}
+	return "", false
+}
+
+// finish stops the recording and removes its temporary dir. If the test run failed, the video is moved to videoPth
+// and the screenshots of the failed test cases are copied into screenshotsDir.
+// It returns the kept video (if any) and the screenshot of each failed test case.
+func (recording *screenRecording) finish(testRun *nunitresult.TestRun, failed bool, videoPth, screenshotsDir string) (string, map[string]string) {
+	close(recording.stop)
+	<-recording.done
+
+	defer func() {
+		if err := os.RemoveAll(recording.dir); err != nil {
+			log.Warnf("Failed to remove simulator recording dir, error: %s", err)
+		}
+	}()
+
+	if err := recording.video.Stop(); err != nil {
+		log.Warnf("Failed to stop simulator video recording, error: %s", err)
+	}
+
+	if !failed {
+		return "", nil
+	}
+
+	keptVideoPth := ""
+	if err := os.Rename(recording.videoPth, videoPth); err != nil {
+		log.Warnf("Failed to save simulator video recording, error: %s", err)
+	} else {
+		keptVideoPth = videoPth
+	}
+
+	if testRun == nil {
+		return keptVideoPth, nil
+	}
+
+	screenshots := map[string]string{}
+	for _, testCase := range testRun.FailedTestCases() {
+		endTime, err := nunitresult.ParseTime(testCase.EndTime)
+		if err != nil {
+			log.Warnf("Failed to determine when (%s) failed, error: %s", testCase.FullName, err)
+			continue
+		}
+
+		screenshotPth, ok := recording.screenshotAt(endTime)
+		if !ok {
+			continue
+		}
+
+		if err := pathutil.EnsureDirExist(screenshotsDir); err != nil {
+			log.Warnf("Failed to create screenshots dir, error: %s", err)
+			break
+		}
+
+		pth := filepath.Join(screenshotsDir, sanitizeFileName(testCase.FullName)+".png")
+		if err := command.CopyFile(screenshotPth, pth); err != nil {
+			log.Warnf("Failed to save screenshot of (%s), error: %s", testCase.FullName, err)
+			continue
+		}
+		log.Printf("screenshot of %s: %s", testCase.FullName, pth)
+		screenshots[testCase.FullName] = pth
+	}
+
+	return keptVideoPth, screenshots
+}
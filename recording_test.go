@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScreenRecordingScreenshots(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "recording")
+	if err != nil {
+		t.Fatalf("Failed to create tmp dir, error: %s", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove tmp dir, error: %s", err)
+		}
+	}()
+
+	start := time.Date(2018, 5, 14, 10, 0, 0, 0, time.UTC)
+	recording := &screenRecording{dir: tmpDir}
+
+	// login screen for 4 s, then the error screen
+	for i, content := range []string{"login", "login", "login", "error"} {
+		pth := filepath.Join(tmpDir, fmt.Sprintf("screenshot_%d.png", i))
+		if err := ioutil.WriteFile(pth, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write screenshot, error: %s", err)
+		}
+		recording.addScreenshot(pth, start.Add(time.Duration(i)*screenshotInterval))
+	}
+
+	files, err := ioutil.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read dir, error: %s", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("%d screenshot files kept, want 2 (identical ones removed)", len(files))
+	}
+
+	tests := []struct {
+		at     time.Duration
+		want   string
+		wantOK bool
+	}{
+		{at: -time.Second, wantOK: false},
+		{at: 0, want: "screenshot_0.png", wantOK: true},
+		// the test failed 5 s into the run, 1 s after the identical screenshot taken at 4 s
+		{at: 5 * time.Second, want: "screenshot_0.png", wantOK: true},
+		{at: 6 * time.Second, want: "screenshot_3.png", wantOK: true},
+		{at: time.Hour, want: "screenshot_3.png", wantOK: true},
+	}
+
+	for _, tt := range tests {
+		pth, ok := recording.screenshotAt(start.Add(tt.at))
+		if ok != tt.wantOK || filepath.Base(pth) != tt.want && tt.wantOK {
+			t.Errorf("screenshotAt(+%s) = %s, %v, want %s, %v", tt.at, pth, ok, tt.want, tt.wantOK)
+			continue
+		}
+		if ok {
+			if _, err := os.Stat(pth); err != nil {
+				t.Errorf("screenshotAt(+%s) = %s, which does not exist", tt.at, pth)
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/nunitresult"
+	"github.com/bitrise-tools/go-xamarin/tools/nunit"
+)
+
+// testOutcome is the result of a test project - project pair, including the retries of the failed tests.
+type testOutcome struct {
+	id testRunID
+
+	resultLogPth string
+	resultLog    string
+	testRun      *nunitresult.TestRun
+
+	attempts         int
+	flakyTests       []string
+	quarantinedTests []string
+
+	diagnosticsPth string
+	videoPth       string
+	screenshots    map[string]string
+	attachments    map[string][]string
+
+	err error
+}
+
+func runNunitConsole(nunitConsole *nunit.Model, resultLogPth string) (string, *nunitresult.TestRun, error) {
+	fmt.Println()
+	log.Infof("Running Xamarin UITest")
+	log.Donef("$ %s", nunitConsole.PrintableCommand())
+	fmt.Println()
+
+	err := nunitConsole.Run()
+	if _, timedOut := err.(nunit.RunTimeoutError); timedOut {
+		log.Errorf("Test run timed out: %s", err)
+		log.Printf("collecting the partial test result...")
+	}
+
+	resultLog, readErr := testResultLogContent(resultLogPth)
+	if readErr != nil {
+		log.Warnf("Failed to read test result, error: %s", readErr)
+		return "", nil, err
+	}
+	log.Printf("test result: %s", resultLogPth)
+
+	testRun, parseErr := nunitresult.Parse([]byte(resultLog))
+	if parseErr != nil {
+		log.Warnf("Failed to parse test result, error: %s", parseErr)
+		return resultLog, nil, err
+	}
+
+	return resultLog, &testRun, err
+}
+
+func writeTestList(dir string, attempt int, testCases []nunitresult.TestCase) (string, error) {
+	testNames := []string{}
+	for _, testCase := range testCases {
+		testNames = append(testNames, testCase.FullName)
+	}
+
+	pth := filepath.Join(dir, fmt.Sprintf("retry_%d_testlist.txt", attempt))
+	if err := fileutil.WriteStringToFile(pth, strings.Join(testNames, "\n")+"\n"); err != nil {
+		return "", fmt.Errorf("Failed to write test list to (%s), error: %s", pth, err)
+	}
+	return pth, nil
+}
+
+// runTests runs the nunit console against the configured test dll,
+// then reruns only the failed test cases up to retryCount times and merges the results.
+func runTests(nunitConsole *nunit.Model, artifacts *artifacts, id testRunID, retryCount int) testOutcome {
+	resultLogPth := artifacts.resultLogPth(id)
+	nunitConsole.SetResultLogPth(resultLogPth)
+
+	resultLog, testRun, err := runNunitConsole(nunitConsole, resultLogPth)
+
+	outcome := testOutcome{
+		id:               id,
+		resultLogPth:     resultLogPth,
+		resultLog:        resultLog,
+		testRun:          testRun,
+		attempts:         1,
+		flakyTests:       []string{},
+		quarantinedTests: []string{},
+		err:              err,
+	}
+
+	if err == nil || testRun == nil || retryCount <= 0 {
+		return outcome
+	}
+
+	testListDir, tmpErr := pathutil.NormalizedOSTempDirPath("nunit-retry")
+	if tmpErr != nil {
+		log.Warnf("Failed to create tmp dir for the test lists, error: %s", tmpErr)
+		return outcome
+	}
+
+	original, originalErr := *testRun, err
+	retries := []nunitresult.TestRun{}
+	merged := original
+
+	for attempt := 1; attempt <= retryCount && outcome.err != nil; attempt++ {
+		if _, timedOut := outcome.err.(nunit.RunTimeoutError); timedOut {
+			break
+		}
+
+		failedTestCases := merged.FailedTestCases()
+		if len(failedTestCases) == 0 {
+			break
+		}
+
+		fmt.Println()
+		log.Warnf("%d test(s) failed, retrying them (%d/%d)...", len(failedTestCases), attempt, retryCount)
+
+		testListPth, err := writeTestList(testListDir, attempt, failedTestCases)
+		if err != nil {
+			log.Warnf("%s", err)
+			break
+		}
+
+		retryResultLogPth := artifacts.retryResultLogPth(id, attempt)
+		// --test and --testlist selections are combined by nunit, only the test list should be run
+		nunitConsole.SetTestToRun("")
+		nunitConsole.SetTestListPth(testListPth)
+		nunitConsole.SetResultLogPth(retryResultLogPth)
+
+		_, retryTestRun, retryErr := runNunitConsole(nunitConsole, retryResultLogPth)
+		outcome.attempts++
+		if _, timedOut := retryErr.(nunit.RunTimeoutError); timedOut {
+			outcome.err = retryErr
+		}
+		if retryTestRun == nil {
+			break
+		}
+
+		retries = append(retries, *retryTestRun)
+		merged, outcome.flakyTests = nunitresult.MergeRetries(original, retries...)
+		outcome.err = retryErr
+
+		// a suite level (SetUp, TearDown) failure is not retried, the run still failed
+		if outcome.err == nil && merged.Result == nunitresult.ResultFailed {
+			outcome.err = originalErr
+		}
+	}
+
+	nunitConsole.SetTestListPth("")
+
+	if len(retries) == 0 {
+		return outcome
+	}
+
+	mergedResultLogPth := artifacts.mergedResultLogPth(id)
+	if err := merged.WriteToFile(mergedResultLogPth); err != nil {
+		log.Warnf("Failed to write merged test result, error: %s", err)
+		return outcome
+	}
+
+	mergedResultLog, err := testResultLogContent(mergedResultLogPth)
+	if err != nil {
+		log.Warnf("Failed to read merged test result, error: %s", err)
+		return outcome
+	}
+
+	outcome.resultLogPth = mergedResultLogPth
+	outcome.resultLog = mergedResultLog
+	outcome.testRun = &merged
+
+	if len(outcome.flakyTests) > 0 {
+		fmt.Println()
+		log.Warnf("%d test(s) passed on retry, reporting them as flaky:", len(outcome.flakyTests))
+		for _, flakyTest := range outcome.flakyTests {
+			log.Warnf("- %s", flakyTest)
+		}
+	}
+
+	return outcome
+}
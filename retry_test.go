@@ -0,0 +1,135 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/nunitresult"
+	"github.com/bitrise-tools/go-xamarin/constants"
+	"github.com/bitrise-tools/go-xamarin/tools/nunit"
+)
+
+const (
+	retryTestCaseA = `<test-case name="A" fullname="Tests.A" result="%s" duration="1" />`
+
+	failedRunWithSetUpFailure = `<?xml version="1.0" encoding="utf-8"?>
+<test-run result="Failed" total="2" passed="1" failed="1">
+  <test-suite type="Assembly" name="Tests.dll" result="Failed" site="Child" total="2" passed="1" failed="1">
+    <test-suite type="TestFixture" name="Tests" fullname="Tests" result="Failed" site="SetUp" total="2" passed="1" failed="1">
+      <failure><message>OneTimeSetUp: app crashed</message></failure>
+      <test-case name="A" fullname="Tests.A" result="Failed" duration="1" />
+      <test-case name="B" fullname="Tests.B" result="Passed" duration="1" />
+    </test-suite>
+  </test-suite>
+</test-run>`
+
+	failedRun = `<?xml version="1.0" encoding="utf-8"?>
+<test-run result="Failed" total="2" passed="1" failed="1">
+  <test-suite type="TestFixture" name="Tests" fullname="Tests" result="Failed" site="Child" total="2" passed="1" failed="1">
+    <test-case name="A" fullname="Tests.A" result="Failed" duration="1" />
+    <test-case name="B" fullname="Tests.B" result="Passed" duration="1" />
+  </test-suite>
+</test-run>`
+
+	passedRetry = `<?xml version="1.0" encoding="utf-8"?>
+<test-run result="Passed" total="1" passed="1">
+  <test-suite type="TestFixture" name="Tests" fullname="Tests" result="Passed" total="1" passed="1">
+    <test-case name="A" fullname="Tests.A" result="Passed" duration="1" />
+  </test-suite>
+</test-run>`
+)
+
+// writeStubNunitConsole writes a mono stub, which writes the first result (and exits with 1),
+// or the retry result (and exits with 0) if a test list is given, into the --result path.
+func writeStubNunitConsole(t *testing.T, dir, firstResult, retryResult string) {
+	firstPth := filepath.Join(dir, "first.xml")
+	retryPth := filepath.Join(dir, "retry.xml")
+	for pth, content := range map[string]string{firstPth: firstResult, retryPth: retryResult} {
+		if err := ioutil.WriteFile(pth, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write result, error: %s", err)
+		}
+	}
+
+	stub := `#!/bin/sh
+result=""
+retry=no
+while [ $# -gt 0 ]; do
+  case "$1" in
+    --result) result="$2"; shift ;;
+    --testlist) retry=yes; shift ;;
+  esac
+  shift
+done
+if [ "$retry" = yes ]; then
+  cp "` + retryPth + `" "$result"
+  exit 0
+fi
+cp "` + firstPth + `" "$result"
+exit 1
+`
+	monoPth := filepath.Join(dir, "mono")
+	if err := ioutil.WriteFile(monoPth, []byte(stub), 0755); err != nil {
+		t.Fatalf("Failed to write stub mono, error: %s", err)
+	}
+	constants.MonoPath = monoPth
+}
+
+func TestRunTestsRetry(t *testing.T) {
+	originalMonoPath := constants.MonoPath
+	defer func() {
+		constants.MonoPath = originalMonoPath
+	}()
+
+	tests := []struct {
+		name        string
+		firstResult string
+		wantErr     bool
+		wantResult  string
+	}{
+		{name: "fail then pass", firstResult: failedRun, wantErr: false, wantResult: nunitresult.ResultPassed},
+		{name: "suite setup failure", firstResult: failedRunWithSetUpFailure, wantErr: true, wantResult: nunitresult.ResultFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, err := ioutil.TempDir("", "retry")
+			if err != nil {
+				t.Fatalf("Failed to create tmp dir, error: %s", err)
+			}
+			defer func() {
+				if err := os.RemoveAll(tmpDir); err != nil {
+					t.Errorf("Failed to remove tmp dir, error: %s", err)
+				}
+			}()
+
+			writeStubNunitConsole(t, tmpDir, tt.firstResult, passedRetry)
+
+			nunitConsole, err := nunit.New(filepath.Join(tmpDir, "nunit3-console.exe"))
+			if err != nil {
+				t.Fatalf("nunit.New() error: %s", err)
+			}
+			nunitConsole.SetDLLPth(filepath.Join(tmpDir, "Tests.dll"))
+
+			outcome := runTests(nunitConsole, newArtifacts(tmpDir), testRunID{testProjectName: "Tests", projectName: "App"}, 2)
+
+			if (outcome.err != nil) != tt.wantErr {
+				t.Errorf("outcome error = %v, wantErr %v", outcome.err, tt.wantErr)
+			}
+			if outcome.attempts != 2 {
+				t.Errorf("attempts = %d, want 2", outcome.attempts)
+			}
+			if !reflect.DeepEqual(outcome.flakyTests, []string{"Tests.A"}) {
+				t.Errorf("flaky tests = %v", outcome.flakyTests)
+			}
+			if outcome.testRun == nil || outcome.testRun.Result != tt.wantResult {
+				t.Fatalf("merged test run = %+v, want result %s", outcome.testRun, tt.wantResult)
+			}
+			if filepath.Base(outcome.resultLogPth) != "Tests_App_merged_TestResult.xml" {
+				t.Errorf("result log = %s, want the merged result", outcome.resultLogPth)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/nunitresult"
+	"github.com/bitrise-tools/go-xamarin/tools/nunit"
+)
+
+// testTimings maps the fully-qualified test names to their historical duration in seconds.
+type testTimings map[string]float64
+
+// readTestTimings reads the historical test durations either from a json file ({"Test.Full.Name": seconds})
+// or from a previous nunit 3 result file.
+func readTestTimings(pth string) (testTimings, error) {
+	timings := testTimings{}
+	if pth == "" {
+		return timings, nil
+	}
+
+	if strings.ToLower(filepath.Ext(pth)) == ".json" {
+		content, err := fileutil.ReadBytesFromFile(pth)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read timing file (%s), error: %s", pth, err)
+		}
+		if err := json.Unmarshal(content, &timings); err != nil {
+			return nil, fmt.Errorf("Failed to parse timing file (%s), error: %s", pth, err)
+		}
+		return timings, nil
+	}
+
+	testRun, err := nunitresult.ParseFile(pth)
+	if err != nil {
+		return nil, err
+	}
+	for _, testCase := range testRun.TestCases() {
+		timings[testCase.FullName] = testCase.Duration
+	}
+	return timings, nil
+}
+
+// exploreTests lists the test cases of the configured test dll, respecting the test selection of the console.
+func exploreTests(nunitConsole *nunit.Model) ([]string, error) {
+	tmpDir, err := pathutil.NormalizedOSTempDirPath("nunit-explore")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create tmp dir, error: %s", err)
+	}
+	explorePth := filepath.Join(tmpDir, "tests.xml")
+
+	nunitConsole.SetExplorePth(explorePth)
+	defer nunitConsole.SetExplorePth("")
+
+	log.Donef("$ %s", nunitConsole.PrintableCommand())
+	if err := nunitConsole.Run(); err != nil {
+		return nil, fmt.Errorf("Failed to explore tests, error: %s", err)
+	}
+
+	testRun, err := nunitresult.ParseFile(explorePth)
+	if err != nil {
+		return nil, err
+	}
+
+	testNames := []string{}
+	for _, testCase := range testRun.TestCases() {
+		testNames = append(testNames, testCase.FullName)
+	}
+	return testNames, nil
+}
+
+// shardTests deterministically splits the tests into shardCount slices and returns the shardIndex-th slice.
+// Without timings tests are distributed by name, otherwise the longest tests are assigned first,
+// always to the shard with the least total duration.
+func shardTests(testNames []string, shardIndex, shardCount int, timings testTimings) []string {
+	names := append([]string{}, testNames...)
+	sort.Strings(names)
+
+	shard := []string{}
+
+	if len(timings) == 0 {
+		for i, name := range names {
+			if i%shardCount == shardIndex {
+				shard = append(shard, name)
+			}
+		}
+		return shard
+	}
+
+	// tests without history are expected to take the average time
+	total := 0.0
+	known := 0
+	for _, name := range names {
+		if duration, ok := timings[name]; ok {
+			total += duration
+			known++
+		}
+	}
+	average := 1.0
+	if known > 0 {
+		average = total / float64(known)
+	}
+
+	duration := func(name string) float64 {
+		if d, ok := timings[name]; ok {
+			return d
+		}
+		return average
+	}
+
+	sort.SliceStable(names, func(i, j int) bool {
+		return duration(names[i]) > duration(names[j])
+	})
+
+	shardDurations := make([]float64, shardCount)
+	for _, name := range names {
+		target := 0
+		for i := 1; i < shardCount; i++ {
+			if shardDurations[i] < shardDurations[target] {
+				target = i
+			}
+		}
+		shardDurations[target] += duration(name)
+
+		if target == shardIndex {
+			shard = append(shard, name)
+		}
+	}
+
+	sort.Strings(shard)
+	return shard
+}
+
+// shardTestList explores the tests of the configured test dll and writes the current shard's tests into a test list.
+// It returns an empty path if no test belongs to the shard.
+func shardTestList(nunitConsole *nunit.Model, shardIndex, shardCount int, timings testTimings) (string, error) {
+	testNames, err := exploreTests(nunitConsole)
+	if err != nil {
+		return "", err
+	}
+
+	shard := shardTests(testNames, shardIndex, shardCount, timings)
+	log.Printf("%d of %d test(s) belong to shard %d/%d", len(shard), len(testNames), shardIndex, shardCount)
+	if len(shard) == 0 {
+		return "", nil
+	}
+
+	dir, err := pathutil.NormalizedOSTempDirPath("nunit-shard")
+	if err != nil {
+		return "", fmt.Errorf("Failed to create tmp dir, error: %s", err)
+	}
+
+	pth := filepath.Join(dir, fmt.Sprintf("shard_%d_of_%d_testlist.txt", shardIndex, shardCount))
+	if err := fileutil.WriteStringToFile(pth, strings.Join(shard, "\n")+"\n"); err != nil {
+		return "", fmt.Errorf("Failed to write test list to (%s), error: %s", pth, err)
+	}
+	return pth, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+)
+
+func TestShardTests(t *testing.T) {
+	testNames := []string{"Tests.E", "Tests.C", "Tests.A", "Tests.D", "Tests.B", "Tests.F", "Tests.G"}
+
+	for _, timings := range []testTimings{
+		nil,
+		{"Tests.A": 60, "Tests.B": 10, "Tests.C": 10, "Tests.D": 20, "Tests.E": 5, "Tests.F": 5},
+	} {
+		for shardCount := 1; shardCount <= len(testNames)+1; shardCount++ {
+			seen := map[string]int{}
+			for shardIndex := 0; shardIndex < shardCount; shardIndex++ {
+				shard := shardTests(testNames, shardIndex, shardCount, timings)
+
+				reversed := []string{}
+				for i := len(testNames) - 1; i >= 0; i-- {
+					reversed = append(reversed, testNames[i])
+				}
+				if again := shardTests(reversed, shardIndex, shardCount, timings); !reflect.DeepEqual(shard, again) {
+					t.Errorf("shard %d/%d is not deterministic: %v != %v", shardIndex, shardCount, shard, again)
+				}
+
+				for _, name := range shard {
+					seen[name]++
+				}
+			}
+
+			for _, name := range testNames {
+				if seen[name] != 1 {
+					t.Errorf("%d shards (timings: %v): %s is assigned to %d shards, want exactly 1", shardCount, timings != nil, name, seen[name])
+				}
+			}
+		}
+	}
+}
+
+func TestShardTestsBalancing(t *testing.T) {
+	testNames := []string{"Tests.A", "Tests.B", "Tests.C", "Tests.D", "Tests.E", "Tests.Unknown"}
+	timings := testTimings{"Tests.A": 60, "Tests.B": 30, "Tests.C": 20, "Tests.D": 5, "Tests.E": 5}
+
+	// longest first, always to the least loaded shard: A (60) | B (30), Unknown (24, the average), C (20) | D (5), E (5)
+	want := [][]string{
+		{"Tests.A", "Tests.D", "Tests.E"},
+		{"Tests.B", "Tests.C", "Tests.Unknown"},
+	}
+	for shardIndex, wantShard := range want {
+		if shard := shardTests(testNames, shardIndex, 2, timings); !reflect.DeepEqual(shard, wantShard) {
+			t.Errorf("shard %d = %v, want %v", shardIndex, shard, wantShard)
+		}
+	}
+
+	// without timings the tests are distributed by name
+	if shard := shardTests(testNames, 0, 2, nil); !reflect.DeepEqual(shard, []string{"Tests.A", "Tests.C", "Tests.E"}) {
+		t.Errorf("shard without timings = %v", shard)
+	}
+}
+
+func TestReadTestTimings(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "test-timings")
+	if err != nil {
+		t.Fatalf("Failed to create tmp dir, error: %s", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove tmp dir, error: %s", err)
+		}
+	}()
+
+	t.Run("json", func(t *testing.T) {
+		pth := filepath.Join(tmpDir, "timings.json")
+		if err := fileutil.WriteStringToFile(pth, `{"Tests.A": 12.5, "Tests.B": 3}`); err != nil {
+			t.Fatalf("Failed to write timing file, error: %s", err)
+		}
+
+		timings, err := readTestTimings(pth)
+		if err != nil {
+			t.Fatalf("readTestTimings() error: %s", err)
+		}
+		if !reflect.DeepEqual(timings, testTimings{"Tests.A": 12.5, "Tests.B": 3}) {
+			t.Errorf("timings = %v", timings)
+		}
+	})
+
+	t.Run("merged result of a previous build", func(t *testing.T) {
+		var resultLogs []string
+		for _, testCase := range []string{
+			`<test-case name="A" fullname="Tests.A" result="Passed" duration="12.5" />`,
+			`<test-case name="B" fullname="Tests.B" result="Failed" duration="3" />`,
+		} {
+			resultLogs = append(resultLogs, `<?xml version="1.0" encoding="utf-8"?><test-run result="Passed"><test-suite type="TestFixture" name="Tests">`+testCase+`</test-suite></test-run>`)
+		}
+		merged, err := mergeResultLogs(resultLogs)
+		if err != nil {
+			t.Fatalf("mergeResultLogs() error: %s", err)
+		}
+
+		pth := filepath.Join(tmpDir, "TestResult.xml")
+		if err := fileutil.WriteStringToFile(pth, merged); err != nil {
+			t.Fatalf("Failed to write result file, error: %s", err)
+		}
+
+		timings, err := readTestTimings(pth)
+		if err != nil {
+			t.Fatalf("readTestTimings() error: %s", err)
+		}
+		if !reflect.DeepEqual(timings, testTimings{"Tests.A": 12.5, "Tests.B": 3}) {
+			t.Errorf("timings = %v", timings)
+		}
+	})
+
+	t.Run("no timing file", func(t *testing.T) {
+		timings, err := readTestTimings("")
+		if err != nil || len(timings) != 0 {
+			t.Errorf("readTestTimings(\"\") = %v, %v", timings, err)
+		}
+	})
+}
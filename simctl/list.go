@@ -0,0 +1,228 @@
+package simctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+)
+
+// Device states
+const (
+	StateShutdown = "Shutdown"
+	StateBooted   = "Booted"
+)
+
+// RuntimeIdentifier is a simulator runtime identifier, like: com.apple.CoreSimulator.SimRuntime.iOS-12-1
+type RuntimeIdentifier string
+
+const runtimeIdentifierPrefix = "com.apple.CoreSimulator.SimRuntime."
+
+// splitOSVersion splits an os version (iOS 12.1) into platform (iOS) and version (12.1).
+func splitOSVersion(osVersion string) (string, string, error) {
+	exp := regexp.MustCompile(`^([a-zA-Z]+)\s*([0-9]+(\.[0-9]+)*)$`)
+	match := exp.FindStringSubmatch(strings.TrimSpace(osVersion))
+	if match == nil {
+		return "", "", fmt.Errorf("invalid os version: %s, expected format: iOS 12.1", osVersion)
+	}
+	return match[1], match[2], nil
+}
+
+// Platform returns the platform part of the identifier (iOS), or an empty string for unknown identifiers.
+func (id RuntimeIdentifier) Platform() string {
+	platform, _ := id.split()
+	return platform
+}
+
+// Version returns the version part of the identifier (12.1), or an empty string for unknown identifiers.
+func (id RuntimeIdentifier) Version() string {
+	_, versionStr := id.split()
+	return versionStr
+}
+
+// OSVersion returns the os version described by the identifier (iOS 12.1).
+func (id RuntimeIdentifier) OSVersion() string {
+	platform, versionStr := id.split()
+	if platform == "" {
+		return string(id)
+	}
+	return platform + " " + versionStr
+}
+
+func (id RuntimeIdentifier) split() (string, string) {
+	if !strings.HasPrefix(string(id), runtimeIdentifierPrefix) {
+		return "", ""
+	}
+
+	parts := strings.Split(strings.TrimPrefix(string(id), runtimeIdentifierPrefix), "-")
+	if len(parts) < 2 {
+		return "", ""
+	}
+	return parts[0], strings.Join(parts[1:], ".")
+}
+
+// AvailabilityFlag is the isAvailable value of simctl list: a bool, or a YES/NO string in Xcode 10.1.
+type AvailabilityFlag bool
+
+// UnmarshalJSON ...
+func (flag *AvailabilityFlag) UnmarshalJSON(data []byte) error {
+	var available bool
+	if err := json.Unmarshal(data, &available); err == nil {
+		*flag = AvailabilityFlag(available)
+		return nil
+	}
+
+	var availableStr string
+	if err := json.Unmarshal(data, &availableStr); err != nil {
+		return fmt.Errorf("invalid isAvailable value: %s", data)
+	}
+	*flag = AvailabilityFlag(strings.ToUpper(availableStr) == "YES")
+	return nil
+}
+
+// DeviceType ...
+type DeviceType struct {
+	Name       string `json:"name"`
+	Identifier string `json:"identifier"`
+}
+
+// Runtime ...
+type Runtime struct {
+	Name         string            `json:"name"`
+	Identifier   RuntimeIdentifier `json:"identifier"`
+	Version      string            `json:"version"`
+	BuildVersion string            `json:"buildversion"`
+
+	// Xcode 10.1 and newer report isAvailable and availabilityError, older ones the availability string
+	IsAvailable       *AvailabilityFlag `json:"isAvailable"`
+	Availability      string            `json:"availability"`
+	AvailabilityError string            `json:"availabilityError"`
+}
+
+// Available ...
+func (runtime Runtime) Available() bool {
+	if runtime.IsAvailable != nil {
+		return bool(*runtime.IsAvailable)
+	}
+	return runtime.Availability == "(available)"
+}
+
+// AvailabilityMessage returns the reason why the runtime is unavailable, if any.
+func (runtime Runtime) AvailabilityMessage() string {
+	return availabilityMessage(runtime.AvailabilityError, runtime.Availability)
+}
+
+// Platform returns the platform of the runtime (iOS).
+func (runtime Runtime) Platform() string {
+	if platform := runtime.Identifier.Platform(); platform != "" {
+		return platform
+	}
+	if platform, _, err := splitOSVersion(runtime.Name); err == nil {
+		return platform
+	}
+	return ""
+}
+
+// OSVersion returns the os version of the runtime (iOS 12.1).
+func (runtime Runtime) OSVersion() string {
+	if runtime.Name != "" {
+		return runtime.Name
+	}
+	return runtime.Identifier.OSVersion()
+}
+
+// Device ...
+type Device struct {
+	Name                 string `json:"name"`
+	UDID                 string `json:"udid"`
+	State                string `json:"state"`
+	DeviceTypeIdentifier string `json:"deviceTypeIdentifier"`
+
+	// Xcode 10.1 and newer report isAvailable and availabilityError, older ones the availability string
+	IsAvailable       *AvailabilityFlag `json:"isAvailable"`
+	Availability      string            `json:"availability"`
+	AvailabilityError string            `json:"availabilityError"`
+}
+
+// Available ...
+func (device Device) Available() bool {
+	if device.IsAvailable != nil {
+		return bool(*device.IsAvailable)
+	}
+	return device.Availability == "(available)"
+}
+
+// AvailabilityMessage returns the reason why the device is unavailable, if any.
+func (device Device) AvailabilityMessage() string {
+	return availabilityMessage(device.AvailabilityError, device.Availability)
+}
+
+// availabilityMessage returns the availabilityError, or the reason from the availability string,
+// like: runtime profile not found from (unavailable, runtime profile not found).
+func availabilityMessage(availabilityError, availability string) string {
+	if availabilityError != "" {
+		return availabilityError
+	}
+	message := strings.TrimSuffix(strings.TrimPrefix(availability, "("), ")")
+	if message == "available" {
+		return ""
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(message, "unavailable"), ", ")
+}
+
+// Inventory is the parsed output of simctl list -j.
+type Inventory struct {
+	DeviceTypes []DeviceType `json:"devicetypes"`
+	Runtimes    []Runtime    `json:"runtimes"`
+	// Devices are keyed by runtime identifier (Xcode 10.1 and newer) or by runtime name (iOS 12.1)
+	Devices map[string][]Device `json:"devices"`
+}
+
+// ParseInventory ...
+func ParseInventory(content []byte) (Inventory, error) {
+	var inventory Inventory
+	if err := json.Unmarshal(content, &inventory); err != nil {
+		return Inventory{}, fmt.Errorf("failed to parse simctl list output, error: %s", err)
+	}
+	return inventory, nil
+}
+
+// List returns the device types, runtimes and devices known by simctl.
+func List() (Inventory, error) {
+	cmd := command.New("xcrun", "simctl", "list", "-j")
+	out, err := cmd.RunAndReturnTrimmedOutput()
+	if err != nil {
+		return Inventory{}, fmt.Errorf("%s failed, output: %s, error: %s", cmd.PrintableCommandArgs(), out, err)
+	}
+	return ParseInventory([]byte(out))
+}
+
+// Runtime returns the runtime with the given os version (iOS 12.1).
+func (inventory Inventory) Runtime(osVersion string) (Runtime, bool) {
+	for _, runtime := range inventory.Runtimes {
+		if runtime.Name == osVersion || runtime.Identifier.OSVersion() == osVersion {
+			return runtime, true
+		}
+	}
+	return Runtime{}, false
+}
+
+// RuntimeDevices returns the devices of the runtime.
+func (inventory Inventory) RuntimeDevices(runtime Runtime) []Device {
+	if devices, ok := inventory.Devices[string(runtime.Identifier)]; ok {
+		return devices
+	}
+	return inventory.Devices[runtime.Name]
+}
+
+// DeviceType returns the device type with the given name (iPhone 8).
+func (inventory Inventory) DeviceType(name string) (DeviceType, bool) {
+	for _, deviceType := range inventory.DeviceTypes {
+		if deviceType.Name == name {
+			return deviceType, true
+		}
+	}
+	return DeviceType{}, false
+}
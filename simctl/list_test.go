@@ -0,0 +1,163 @@
+package simctl
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func parseInventoryFixture(t *testing.T, name string) Inventory {
+	content, err := ioutil.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("Failed to read fixture (%s), error: %s", name, err)
+	}
+
+	inventory, err := ParseInventory(content)
+	if err != nil {
+		t.Fatalf("ParseInventory(%s) error: %s", name, err)
+	}
+	return inventory
+}
+
+func TestParseInventory(t *testing.T) {
+	tests := []struct {
+		fixture string
+		// os version -> udids of the available devices
+		wantDevices     map[string][]string
+		wantUnavailable map[string]string
+	}{
+		{
+			fixture: "list_xcode10.0.json",
+			wantDevices: map[string][]string{
+				"iOS 11.3": {},
+				"iOS 12.0": {"0C1B6B0B-2E6E-4E2A-8B0B-6D2C9E1A2B02", "7A2E9C4D-1F3B-4C5A-9E8D-0B1C2D3E4F03"},
+			},
+			wantUnavailable: map[string]string{"5B6A1E2F-6C35-4E4E-9C3E-3B1B5E4E1A01": "runtime profile not found"},
+		},
+		{
+			fixture: "list_xcode10.1.json",
+			wantDevices: map[string][]string{
+				"iOS 12.0": {},
+				"iOS 12.1": {"2B3C4D5E-6F7A-4B8C-9D0E-1F2A3B4C5D06"},
+			},
+			wantUnavailable: map[string]string{"1A2B3C4D-5E6F-4A7B-8C9D-0E1F2A3B4C05": "runtime profile not found"},
+		},
+		{
+			fixture: "list_xcode10.2.json",
+			wantDevices: map[string][]string{
+				"iOS 12.1": {},
+				"iOS 12.2": {"4D5E6F7A-8B9C-4D0E-1F2A-3B4C5D6E7F08", "5E6F7A8B-9C0D-4E1F-2A3B-4C5D6E7F8A09"},
+			},
+			wantUnavailable: map[string]string{"3C4D5E6F-7A8B-4C9D-0E1F-2A3B4C5D6E07": "runtime profile not found"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			inventory := parseInventoryFixture(t, tt.fixture)
+
+			if _, ok := inventory.DeviceType("iPhone 8"); !ok {
+				t.Errorf("DeviceType(iPhone 8) not found")
+			}
+
+			for osVersion, wantUDIDs := range tt.wantDevices {
+				runtime, ok := inventory.Runtime(osVersion)
+				if !ok {
+					t.Errorf("Runtime(%s) not found", osVersion)
+					continue
+				}
+
+				availableUDIDs := []string{}
+				for _, device := range inventory.RuntimeDevices(runtime) {
+					if device.Available() {
+						availableUDIDs = append(availableUDIDs, device.UDID)
+					} else if want := tt.wantUnavailable[device.UDID]; device.AvailabilityMessage() != want {
+						t.Errorf("%s AvailabilityMessage() = %s, want %s", device.UDID, device.AvailabilityMessage(), want)
+					}
+				}
+
+				if len(availableUDIDs) != len(wantUDIDs) {
+					t.Errorf("%s available devices = %v, want %v", osVersion, availableUDIDs, wantUDIDs)
+					continue
+				}
+				for i := range wantUDIDs {
+					if availableUDIDs[i] != wantUDIDs[i] {
+						t.Errorf("%s available devices = %v, want %v", osVersion, availableUDIDs, wantUDIDs)
+						break
+					}
+				}
+
+				if runtime.Available() != (len(wantUDIDs) > 0) {
+					t.Errorf("%s Available() = %v", osVersion, runtime.Available())
+				}
+				wantMessage := ""
+				if !runtime.Available() {
+					wantMessage = "runtime profile not found"
+				}
+				if runtime.AvailabilityMessage() != wantMessage {
+					t.Errorf("%s AvailabilityMessage() = %s, want %s", osVersion, runtime.AvailabilityMessage(), wantMessage)
+				}
+			}
+		})
+	}
+}
+
+func TestParseInventoryError(t *testing.T) {
+	if _, err := ParseInventory([]byte(`{"runtimes": [{"isAvailable": 1}]}`)); err == nil {
+		t.Errorf("ParseInventory() expected error for an invalid isAvailable value")
+	}
+	if _, err := ParseInventory([]byte(`xcrun: error: unable to find utility "simctl"`)); err == nil {
+		t.Errorf("ParseInventory() expected error for a non json output")
+	}
+}
+
+func TestRuntimeIdentifier(t *testing.T) {
+	tests := []struct {
+		id           RuntimeIdentifier
+		wantPlatform string
+		wantVersion  string
+		wantOS       string
+	}{
+		{id: "com.apple.CoreSimulator.SimRuntime.iOS-12-1", wantPlatform: "iOS", wantVersion: "12.1", wantOS: "iOS 12.1"},
+		{id: "com.apple.CoreSimulator.SimRuntime.iOS-10-3-1", wantPlatform: "iOS", wantVersion: "10.3.1", wantOS: "iOS 10.3.1"},
+		{id: "com.apple.CoreSimulator.SimRuntime.watchOS-5-2", wantPlatform: "watchOS", wantVersion: "5.2", wantOS: "watchOS 5.2"},
+		{id: "com.apple.CoreSimulator.SimRuntime.iOS", wantOS: "com.apple.CoreSimulator.SimRuntime.iOS"},
+		{id: "iOS 12.1", wantOS: "iOS 12.1"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.id.Platform(); got != tt.wantPlatform {
+			t.Errorf("%s Platform() = %s, want %s", tt.id, got, tt.wantPlatform)
+		}
+		if got := tt.id.Version(); got != tt.wantVersion {
+			t.Errorf("%s Version() = %s, want %s", tt.id, got, tt.wantVersion)
+		}
+		if got := tt.id.OSVersion(); got != tt.wantOS {
+			t.Errorf("%s OSVersion() = %s, want %s", tt.id, got, tt.wantOS)
+		}
+	}
+}
+
+func TestAvailabilityMessage(t *testing.T) {
+	tests := []struct {
+		availabilityError string
+		availability      string
+		want              string
+	}{
+		{availability: "(available)", want: ""},
+		{availability: "(unavailable, runtime profile not found)", want: "runtime profile not found"},
+		{availability: "(unavailable)", want: ""},
+		{availabilityError: "runtime profile not found", want: "runtime profile not found"},
+		{},
+	}
+
+	for _, tt := range tests {
+		runtime := Runtime{Availability: tt.availability, AvailabilityError: tt.availabilityError}
+		if got := runtime.AvailabilityMessage(); got != tt.want {
+			t.Errorf("Runtime%+v AvailabilityMessage() = %q, want %q", tt, got, tt.want)
+		}
+		device := Device{Availability: tt.availability, AvailabilityError: tt.availabilityError}
+		if got := device.AvailabilityMessage(); got != tt.want {
+			t.Errorf("Device%+v AvailabilityMessage() = %q, want %q", tt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package simctl
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+)
+
+// BackgroundProcess is a long running simctl command, like streaming the simulator log.
+type BackgroundProcess struct {
+	cmd  *command.Model
+	done chan error
+}
+
+func startBackgroundProcess(out io.Writer, args ...string) (*BackgroundProcess, error) {
+	cmd := command.New("xcrun", append([]string{"simctl"}, args...)...)
+	cmd.SetStdout(out)
+	cmd.SetStderr(out)
+
+	if err := cmd.GetCmd().Start(); err != nil {
+		return nil, fmt.Errorf("%s failed, error: %s", cmd.PrintableCommandArgs(), err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.GetCmd().Wait()
+	}()
+
+	return &BackgroundProcess{cmd: cmd, done: done}, nil
+}
+
+// Stop interrupts the process and waits for it to exit, the process is killed if it does not exit in 10 seconds.
+func (process *BackgroundProcess) Stop() error {
+	if err := process.cmd.GetCmd().Process.Signal(os.Interrupt); err != nil {
+		select {
+		case <-process.done:
+			// already exited
+			return nil
+		default:
+			return fmt.Errorf("failed to interrupt %s, error: %s", process.cmd.PrintableCommandArgs(), err)
+		}
+	}
+
+	select {
+	case <-process.done:
+		return nil
+	case <-time.After(10 * time.Second):
+		if err := process.cmd.GetCmd().Process.Kill(); err != nil {
+			return fmt.Errorf("failed to kill %s, error: %s", process.cmd.PrintableCommandArgs(), err)
+		}
+		<-process.done
+		return nil
+	}
+}
+
+// StreamLog streams the system log of a booted simulator into out, until the returned process is stopped.
+func StreamLog(udid string, out io.Writer) (*BackgroundProcess, error) {
+	return startBackgroundProcess(out, "spawn", udid, "log", "stream", "--style", "compact")
+}
+
+// RecordVideo records the screen of a booted simulator into pth, until the returned process is stopped.
+func RecordVideo(udid, pth string) (*BackgroundProcess, error) {
+	return startBackgroundProcess(ioutil.Discard, "io", udid, "recordVideo", pth)
+}
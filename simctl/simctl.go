@@ -0,0 +1,115 @@
+package simctl
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+)
+
+func simctl(args ...string) (string, error) {
+	cmd := command.New("xcrun", append([]string{"simctl"}, args...)...)
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return out, fmt.Errorf("%s failed, output: %s, error: %s", cmd.PrintableCommandArgs(), out, err)
+	}
+	return out, nil
+}
+
+// Create creates a new simulator and returns its udid.
+// deviceType is either a device type name (iPhone 8) or identifier (com.apple.CoreSimulator.SimDeviceType.iPhone-8).
+func Create(name, deviceType string, runtimeIdentifier RuntimeIdentifier) (string, error) {
+	out, err := simctl("create", name, deviceType, string(runtimeIdentifier))
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(out, "\n")
+	udid := strings.TrimSpace(lines[len(lines)-1])
+	if udid == "" {
+		return "", fmt.Errorf("failed to create simulator, no udid returned")
+	}
+	return udid, nil
+}
+
+// Delete deletes the simulator, a booted simulator is shut down first.
+func Delete(udid string) error {
+	if err := Shutdown(udid); err != nil {
+		return err
+	}
+	_, err := simctl("delete", udid)
+	return err
+}
+
+// Shutdown shuts down the simulator, it is not an error if the simulator is not booted.
+func Shutdown(udid string) error {
+	out, err := simctl("shutdown", udid)
+	if err != nil && strings.Contains(out, "current state: Shutdown") {
+		return nil
+	}
+	return err
+}
+
+// Boot boots the simulator, it is not an error if the simulator is already booted.
+func Boot(udid string) error {
+	out, err := simctl("boot", udid)
+	if err != nil && strings.Contains(out, "current state: Booted") {
+		return nil
+	}
+	return err
+}
+
+// WaitForBoot waits until the simulator finished booting, or the timeout elapses.
+func WaitForBoot(udid string, timeout time.Duration) error {
+	cmd := command.New("xcrun", "simctl", "bootstatus", udid)
+
+	var out bytes.Buffer
+	cmd.SetStdout(&out)
+	cmd.SetStderr(&out)
+
+	if err := cmd.GetCmd().Start(); err != nil {
+		return fmt.Errorf("%s failed, error: %s", cmd.PrintableCommandArgs(), err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.GetCmd().Wait()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("%s failed, output: %s, error: %s", cmd.PrintableCommandArgs(), strings.TrimSpace(out.String()), err)
+		}
+		return nil
+	case <-time.After(timeout):
+		if err := cmd.GetCmd().Process.Kill(); err != nil {
+			return fmt.Errorf("simulator (%s) did not boot in %s, failed to kill bootstatus, error: %s", udid, timeout, err)
+		}
+		return fmt.Errorf("simulator (%s) did not boot in %s", udid, timeout)
+	}
+}
+
+// Erase erases the content and settings of the simulator.
+// simctl can only erase a shut down simulator, so a booted simulator is shut down first.
+func Erase(udid string) error {
+	if err := Shutdown(udid); err != nil {
+		return fmt.Errorf("failed to shut down simulator (%s) before erase, error: %s", udid, err)
+	}
+
+	if out, err := simctl("erase", udid); err != nil {
+		if strings.Contains(out, "Invalid device") {
+			return fmt.Errorf("simulator (%s) not found, error: %s", udid, err)
+		}
+		return fmt.Errorf("failed to erase simulator (%s), error: %s", udid, err)
+	}
+	return nil
+}
+
+// Screenshot saves a screenshot of a booted simulator to pth.
+func Screenshot(udid, pth string) error {
+	_, err := simctl("io", udid, "screenshot", pth)
+	return err
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/simctl"
+	"github.com/bitrise-tools/go-xamarin/tools/nunit"
+	"github.com/bitrise-tools/go-xcode/simulator"
+)
+
+// simulatorSpec is a device name - os version pair, as set in the step inputs.
+type simulatorSpec struct {
+	device    string
+	osVersion string
+}
+
+// simulatorTarget is a resolved simulator the tests run on.
+type simulatorTarget struct {
+	info      simulator.InfoModel
+	osVersion string
+}
+
+func (target simulatorTarget) name() string {
+	return fmt.Sprintf("%s_%s", target.info.Name, target.osVersion)
+}
+
+// parseSimulatorMatrix parses the comma separated list of device@os version entries,
+// the os version defaults to latest.
+// Format example: iPhone 8@iOS 12.1, iPad Air 2@latest
+func parseSimulatorMatrix(matrix string) ([]simulatorSpec, error) {
+	specs := []simulatorSpec{}
+	for _, entry := range splitCommaSeparatedList(matrix) {
+		device, osVersion := entry, "latest"
+		if idx := strings.LastIndex(entry, "@"); idx != -1 {
+			device = strings.TrimSpace(entry[:idx])
+			osVersion = strings.TrimSpace(entry[idx+1:])
+		}
+
+		if device == "" || osVersion == "" {
+			return nil, fmt.Errorf("invalid simulator matrix entry: %s, expected format: device@os version", entry)
+		}
+
+		specs = append(specs, simulatorSpec{device: device, osVersion: osVersion})
+	}
+
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no simulator found in matrix: %s", matrix)
+	}
+
+	return specs, nil
+}
+
+// createSimulator creates a new simulator of the given device type, on the highest runtime matching the os version.
+func createSimulator(inventory simctl.Inventory, spec simulatorSpec, policy string) (simulatorTarget, error) {
+	runtimes, err := candidateRuntimes(inventory, spec.osVersion, policy)
+	if err != nil {
+		return simulatorTarget{}, err
+	}
+	runtime := runtimes[0]
+	osVersion := runtime.OSVersion()
+
+	deviceType := spec.device
+	if dt, ok := inventory.DeviceType(spec.device); ok {
+		deviceType = dt.Identifier
+	}
+
+	name := fmt.Sprintf("Bitrise UITest %s %s", spec.device, osVersion)
+	udid, err := simctl.Create(name, deviceType, runtime.Identifier)
+	if err != nil {
+		return simulatorTarget{}, fmt.Errorf("Failed to create simulator (%s), error: %s", name, err)
+	}
+
+	return simulatorTarget{
+		info: simulator.InfoModel{
+			Name:   spec.device,
+			ID:     udid,
+			Status: simctl.StateShutdown,
+		},
+		osVersion: osVersion,
+	}, nil
+}
+
+// testRunOptions configures how the tests of a test project - project pair are run on a simulator.
+type testRunOptions struct {
+	retryCount         int
+	collectDiagnostics bool
+	bundleID           string
+	recordSimulator    bool
+	collectAttachments bool
+	// screenshotDirs are the dirs where the app.Screenshot() images of the run are searched
+	screenshotDirs []string
+}
+
+// runTestsOnSimulator runs the tests on the given simulator,
+// capturing the simulator diagnostics and recording the screen around the run if requested.
+func runTestsOnSimulator(nunitConsole *nunit.Model, artifacts *artifacts, id testRunID, target simulatorTarget, options testRunOptions) testOutcome {
+	nunitConsole.SetEnvs("IOS_SIMULATOR_UDID=" + target.info.ID)
+
+	var capture *diagnosticsCapture
+	if options.collectDiagnostics {
+		var err error
+		if capture, err = startDiagnosticsCapture(target.info.ID, options.bundleID); err != nil {
+			log.Warnf("Failed to start collecting simulator diagnostics, error: %s", err)
+		}
+	}
+
+	var recording *screenRecording
+	if options.recordSimulator {
+		var err error
+		if recording, err = startScreenRecording(target.info.ID); err != nil {
+			log.Warnf("Failed to start recording the simulator, error: %s", err)
+		}
+	}
+
+	startTime := time.Now()
+	outcome := runTests(nunitConsole, artifacts, id, options.retryCount)
+
+	if options.collectAttachments {
+		outcome.attachments = collectAttachments(outcome.testRun, options.screenshotDirs, startTime, artifacts.attachmentsDir(id))
+	}
+
+	if recording != nil {
+		videoPth, screenshots := recording.finish(outcome.testRun, outcome.err != nil, artifacts.recordingPth(id), artifacts.screenshotsDir(id))
+		if videoPth != "" {
+			log.Printf("simulator recording: %s", videoPth)
+		}
+		outcome.videoPth = videoPth
+		outcome.screenshots = screenshots
+	}
+
+	if capture != nil {
+		diagnosticsPth := artifacts.diagnosticsZipPth(id)
+		if err := capture.finish(diagnosticsPth); err != nil {
+			log.Warnf("Failed to collect simulator diagnostics, error: %s", err)
+		} else {
+			log.Printf("simulator diagnostics: %s", diagnosticsPth)
+			outcome.diagnosticsPth = diagnosticsPth
+		}
+	}
+
+	return outcome
+}
+
+// runTestsOnSimulators runs the configured tests on every simulator concurrently,
+// each nunit console process gets its own simulator udid and result log.
+// The returned outcomes are in the order of the targets.
+func runTestsOnSimulators(nunitConsole *nunit.Model, artifacts *artifacts, testProjectName, projectName string, targets []simulatorTarget, options testRunOptions) []testOutcome {
+	if len(targets) == 1 {
+		console := *nunitConsole
+
+		id := testRunID{testProjectName: testProjectName, projectName: projectName}
+		return []testOutcome{runTestsOnSimulator(&console, artifacts, id, targets[0], options)}
+	}
+
+	outcomes := make([]testOutcome, len(targets))
+	outputLogPths := make([]string, len(targets))
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+
+		go func(i int, target simulatorTarget) {
+			defer wg.Done()
+
+			id := testRunID{testProjectName: testProjectName, projectName: projectName, simulatorName: target.name()}
+
+			console := *nunitConsole
+
+			outputLogPth := artifacts.outputLogPth(id)
+			if outputFile, err := os.Create(outputLogPth); err != nil {
+				log.Warnf("Failed to create output log (%s), error: %s", outputLogPth, err)
+			} else {
+				defer func() {
+					if err := outputFile.Close(); err != nil {
+						log.Warnf("Failed to close output log (%s), error: %s", outputLogPth, err)
+					}
+				}()
+
+				console.SetOutput(outputFile)
+				outputLogPths[i] = outputLogPth
+			}
+
+			log.Printf("testing on simulator: %s (%s)", target.name(), target.info.ID)
+
+			outcomes[i] = runTestsOnSimulator(&console, artifacts, id, target, options)
+		}(i, target)
+	}
+	wg.Wait()
+
+	for i, target := range targets {
+		if outputLogPths[i] == "" {
+			continue
+		}
+
+		fmt.Println()
+		log.Infof("Output of the tests on simulator: %s", target.name())
+		if output, err := fileutil.ReadStringFromFile(outputLogPths[i]); err != nil {
+			log.Warnf("Failed to read output log (%s), error: %s", outputLogPths[i], err)
+		} else {
+			fmt.Println(output)
+		}
+	}
+
+	return outcomes
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-tools/go-xamarin/tools/nunit"
+	"github.com/bitrise-tools/go-xcode/simulator"
+)
+
+const (
+	testRunResultSucceeded = "succeeded"
+	testRunResultFailed    = "failed"
+	testRunResultTimedOut  = "timed_out"
+)
+
+// simulatorSummary ...
+type simulatorSummary struct {
+	UDID      string `json:"udid"`
+	Name      string `json:"name"`
+	OSVersion string `json:"os_version"`
+}
+
+// testCountsSummary ...
+type testCountsSummary struct {
+	Total        int `json:"total"`
+	Passed       int `json:"passed"`
+	Failed       int `json:"failed"`
+	Skipped      int `json:"skipped"`
+	Inconclusive int `json:"inconclusive"`
+}
+
+func (counts *testCountsSummary) add(other testCountsSummary) {
+	counts.Total += other.Total
+	counts.Passed += other.Passed
+	counts.Failed += other.Failed
+	counts.Skipped += other.Skipped
+	counts.Inconclusive += other.Inconclusive
+}
+
+// testRunSummary describes a single nunit run of a test project against an app project.
+type testRunSummary struct {
+	TestProject string              `json:"test_project"`
+	App         string              `json:"app"`
+	AppPath     string              `json:"app_path"`
+	Simulator   simulatorSummary    `json:"simulator"`
+	ResultLog   string              `json:"result_log,omitempty"`
+	Diagnostics string              `json:"diagnostics,omitempty"`
+	Video       string              `json:"video,omitempty"`
+	Screenshots map[string]string   `json:"screenshots,omitempty"`
+	Attachments map[string][]string `json:"attachments,omitempty"`
+
+	Result      string   `json:"result"`
+	Error       string   `json:"error,omitempty"`
+	Duration    float64  `json:"duration"`
+	FailedTests []string `json:"failed_tests"`
+	FlakyTests  []string `json:"flaky_tests"`
+	Attempts    int      `json:"attempts"`
+
+	QuarantinedTests []string `json:"quarantined_tests"`
+
+	testCountsSummary
+}
+
+// testSummary is the machine-readable summary of every test run of the step.
+type testSummary struct {
+	Result   string           `json:"result"`
+	Duration float64          `json:"duration"`
+	TestRuns []testRunSummary `json:"test_runs"`
+
+	testCountsSummary
+}
+
+func newSimulatorSummary(simulatorInfo simulator.InfoModel, osVersion string) simulatorSummary {
+	return simulatorSummary{
+		UDID:      simulatorInfo.ID,
+		Name:      simulatorInfo.Name,
+		OSVersion: osVersion,
+	}
+}
+
+func newTestRunSummary(testProjectName, projectName, appPth string, simulator simulatorSummary, outcome testOutcome) testRunSummary {
+	summary := testRunSummary{
+		TestProject: testProjectName,
+		App:         projectName,
+		AppPath:     appPth,
+		Simulator:   simulator,
+		Result:      testRunResultSucceeded,
+		FailedTests: []string{},
+		FlakyTests:  outcome.flakyTests,
+		Attempts:    outcome.attempts,
+
+		QuarantinedTests: outcome.quarantinedTests,
+
+		Diagnostics: outcome.diagnosticsPth,
+		Video:       outcome.videoPth,
+		Screenshots: outcome.screenshots,
+		Attachments: outcome.attachments,
+	}
+
+	if testRun := outcome.testRun; testRun != nil {
+		summary.ResultLog = outcome.resultLogPth
+		summary.Duration = testRun.Duration
+		summary.testCountsSummary = testCountsSummary{
+			Total:        testRun.Total,
+			Passed:       testRun.Passed,
+			Failed:       testRun.Failed,
+			Skipped:      testRun.Skipped,
+			Inconclusive: testRun.Inconclusive,
+		}
+
+		for _, testCase := range testRun.FailedTestCases() {
+			summary.FailedTests = append(summary.FailedTests, testCase.FullName)
+		}
+	}
+
+	// quarantined failures do not fail the test run, but they are still listed
+	if outcome.err != nil {
+		summary.Result = testRunResultFailed
+		summary.Error = outcome.err.Error()
+
+		if _, timedOut := outcome.err.(nunit.RunTimeoutError); timedOut {
+			summary.Result = testRunResultTimedOut
+		}
+	}
+
+	return summary
+}
+
+func newTestSummary(testRunSummaries []testRunSummary) testSummary {
+	summary := testSummary{
+		Result:   testRunResultSucceeded,
+		TestRuns: testRunSummaries,
+	}
+
+	for _, testRunSummary := range testRunSummaries {
+		if testRunSummary.Result != testRunResultSucceeded {
+			summary.Result = testRunResultFailed
+		}
+		summary.Duration += testRunSummary.Duration
+		summary.add(testRunSummary.testCountsSummary)
+	}
+
+	return summary
+}
+
+func (summary testSummary) writeToFile(pth string) error {
+	content, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to marshal test summary, error: %s", err)
+	}
+
+	if err := fileutil.WriteBytesToFile(pth, content); err != nil {
+		return fmt.Errorf("Failed to write test summary to (%s), error: %s", pth, err)
+	}
+
+	return nil
+}
+
+// flakyTest ...
+type flakyTest struct {
+	TestProject string `json:"test_project"`
+	App         string `json:"app"`
+	Name        string `json:"name"`
+}
+
+func writeFlakyTests(testRunSummaries []testRunSummary, pth string) error {
+	flakyTests := []flakyTest{}
+	for _, testRunSummary := range testRunSummaries {
+		for _, name := range testRunSummary.FlakyTests {
+			flakyTests = append(flakyTests, flakyTest{
+				TestProject: testRunSummary.TestProject,
+				App:         testRunSummary.App,
+				Name:        name,
+			})
+		}
+	}
+
+	content, err := json.MarshalIndent(flakyTests, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to marshal flaky tests, error: %s", err)
+	}
+
+	if err := fileutil.WriteBytesToFile(pth, content); err != nil {
+		return fmt.Errorf("Failed to write flaky tests to (%s), error: %s", pth, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitrise-steplib/steps-xamarin-ios-test/nunitresult"
+	"github.com/bitrise-tools/go-xamarin/tools/nunit"
+	"github.com/bitrise-tools/go-xcode/simulator"
+)
+
+func TestTestSummaryJSON(t *testing.T) {
+	testRun := func(duration float64, results ...string) *nunitresult.TestRun {
+		run := nunitresult.TestRun{Total: len(results), Duration: duration}
+		testCases := []nunitresult.TestCase{}
+		for i, result := range results {
+			name := string('A' + byte(i))
+			testCases = append(testCases, nunitresult.TestCase{Name: name, FullName: "Tests." + name, Result: result})
+			switch result {
+			case nunitresult.ResultPassed:
+				run.Passed++
+			case nunitresult.ResultFailed:
+				run.Failed++
+			case nunitresult.ResultSkipped:
+				run.Skipped++
+			case nunitresult.ResultInconclusive:
+				run.Inconclusive++
+			}
+		}
+		run.TestSuites = []nunitresult.TestSuite{{Name: "Tests", TestCases: testCases}}
+		return &run
+	}
+
+	sim := newSimulatorSummary(simulator.InfoModel{ID: "0C1B6B0B-2E6E-4E2A-8B0B-6D2C9E1A2B02", Name: "iPhone 8"}, "iOS 12.0")
+
+	testRunSummaries := []testRunSummary{
+		newTestRunSummary("MyApp.UITests", "MyApp.iOS", "/build/MyApp.iOS.app", sim, testOutcome{
+			resultLogPth:     "/deploy/MyApp.UITests_MyApp.iOS_merged_TestResult.xml",
+			testRun:          testRun(12.5, nunitresult.ResultPassed, nunitresult.ResultPassed, nunitresult.ResultSkipped, nunitresult.ResultInconclusive),
+			attempts:         2,
+			flakyTests:       []string{"Tests.B"},
+			quarantinedTests: []string{},
+		}),
+		// the failure is quarantined: the run succeeds, but the test is still listed as failed
+		newTestRunSummary("MyApp.SmokeTests", "MyApp.iOS", "/build/MyApp.iOS.app", sim, testOutcome{
+			resultLogPth:     "/deploy/MyApp.SmokeTests_MyApp.iOS_TestResult.xml",
+			testRun:          testRun(2.25, nunitresult.ResultPassed, nunitresult.ResultFailed),
+			attempts:         1,
+			flakyTests:       []string{},
+			quarantinedTests: []string{"Tests.B"},
+		}),
+		// no test result was written before the deadline
+		newTestRunSummary("MyApp.UITests", "MyApp.Lite", "/build/MyApp.Lite.app", sim, testOutcome{
+			resultLogPth:     "/deploy/MyApp.UITests_MyApp.Lite_TestResult.xml",
+			attempts:         1,
+			flakyTests:       []string{},
+			quarantinedTests: []string{},
+			videoPth:         "/deploy/MyApp.UITests_MyApp.Lite.mp4",
+			err:              nunit.RunTimeoutError{Deadline: time.Date(2019, 3, 1, 11, 0, 0, 0, time.UTC)},
+		}),
+	}
+
+	tmpDir, err := ioutil.TempDir("", "summary")
+	if err != nil {
+		t.Fatalf("Failed to create tmp dir, error: %s", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove tmp dir, error: %s", err)
+		}
+	}()
+
+	pth := filepath.Join(tmpDir, "test_summary.json")
+	if err := newTestSummary(testRunSummaries).writeToFile(pth); err != nil {
+		t.Fatalf("writeToFile() error: %s", err)
+	}
+
+	content, err := ioutil.ReadFile(pth)
+	if err != nil {
+		t.Fatalf("Failed to read test summary, error: %s", err)
+	}
+
+	want := `{
+  "result": "failed",
+  "duration": 14.75,
+  "test_runs": [
+    {
+      "test_project": "MyApp.UITests",
+      "app": "MyApp.iOS",
+      "app_path": "/build/MyApp.iOS.app",
+      "simulator": {
+        "udid": "0C1B6B0B-2E6E-4E2A-8B0B-6D2C9E1A2B02",
+        "name": "iPhone 8",
+        "os_version": "iOS 12.0"
+      },
+      "result_log": "/deploy/MyApp.UITests_MyApp.iOS_merged_TestResult.xml",
+      "result": "succeeded",
+      "duration": 12.5,
+      "failed_tests": [],
+      "flaky_tests": [
+        "Tests.B"
+      ],
+      "attempts": 2,
+      "quarantined_tests": [],
+      "total": 4,
+      "passed": 2,
+      "failed": 0,
+      "skipped": 1,
+      "inconclusive": 1
+    },
+    {
+      "test_project": "MyApp.SmokeTests",
+      "app": "MyApp.iOS",
+      "app_path": "/build/MyApp.iOS.app",
+      "simulator": {
+        "udid": "0C1B6B0B-2E6E-4E2A-8B0B-6D2C9E1A2B02",
+        "name": "iPhone 8",
+        "os_version": "iOS 12.0"
+      },
+      "result_log": "/deploy/MyApp.SmokeTests_MyApp.iOS_TestResult.xml",
+      "result": "succeeded",
+      "duration": 2.25,
+      "failed_tests": [
+        "Tests.B"
+      ],
+      "flaky_tests": [],
+      "attempts": 1,
+      "quarantined_tests": [
+        "Tests.B"
+      ],
+      "total": 2,
+      "passed": 1,
+      "failed": 1,
+      "skipped": 0,
+      "inconclusive": 0
+    },
+    {
+      "test_project": "MyApp.UITests",
+      "app": "MyApp.Lite",
+      "app_path": "/build/MyApp.Lite.app",
+      "simulator": {
+        "udid": "0C1B6B0B-2E6E-4E2A-8B0B-6D2C9E1A2B02",
+        "name": "iPhone 8",
+        "os_version": "iOS 12.0"
+      },
+      "video": "/deploy/MyApp.UITests_MyApp.Lite.mp4",
+      "result": "timed_out",
+      "error": "nunit console did not finish until the deadline (2019-03-01T11:00:00Z), the process was killed",
+      "duration": 0,
+      "failed_tests": [],
+      "flaky_tests": [],
+      "attempts": 1,
+      "quarantined_tests": [],
+      "total": 0,
+      "passed": 0,
+      "failed": 0,
+      "skipped": 0,
+      "inconclusive": 0
+    }
+  ],
+  "total": 6,
+  "passed": 3,
+  "failed": 1,
+  "skipped": 1,
+  "inconclusive": 1
+}`
+	if string(content) != want {
+		t.Errorf("test summary =\n%s\nwant\n%s", content, want)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-tools/go-xamarin/builder"
+	"github.com/bitrise-tools/go-xamarin/constants"
+)
+
+// testPair is a test project's assembly to run against an app project's .app.
+type testPair struct {
+	testProjectName string
+	projectName     string
+
+	testDllPth string
+	appPth     string
+}
+
+// builtTestPairs pairs the built test projects with the built apps of their referred projects,
+// selected by the test project and app project filters.
+func builtTestPairs(testProjectOutputMap builder.TestProjectOutputMap, projectOutputMap builder.ProjectOutputMap, testProjectFilter, projectFilter nameFilter) ([]testPair, error) {
+	testProjectNames := []string{}
+	for testProjectName := range testProjectOutputMap {
+		testProjectNames = append(testProjectNames, testProjectName)
+	}
+	sort.Strings(testProjectNames)
+
+	pairs := []testPair{}
+
+	for _, testProjectName := range testProjectNames {
+		if !testProjectFilter.matches(testProjectName) {
+			continue
+		}
+
+		testProjectOutput := testProjectOutputMap[testProjectName]
+		if len(testProjectOutput.ReferredProjectNames) == 0 {
+			log.Warnf("Test project (%s) does not refers to any project, skipping...", testProjectName)
+			continue
+		}
+
+		for _, projectName := range testProjectOutput.ReferredProjectNames {
+			if !projectFilter.matches(projectName) {
+				continue
+			}
+
+			projectOutput, ok := projectOutputMap[projectName]
+			if !ok {
+				continue
+			}
+
+			appPth := ""
+			for _, output := range projectOutput.Outputs {
+				if output.OutputType == constants.OutputTypeAPP {
+					appPth = output.Pth
+				}
+			}
+
+			if appPth == "" {
+				return nil, fmt.Errorf("No app generated for project: %s", projectName)
+			}
+
+			pairs = append(pairs, testPair{
+				testProjectName: testProjectName,
+				projectName:     projectName,
+				testDllPth:      testProjectOutput.Output.Pth,
+				appPth:          appPth,
+			})
+		}
+	}
+
+	if len(pairs) == 0 && (testProjectFilter.active() || projectFilter.active()) {
+		return nil, fmt.Errorf("No test project - app project pair left after applying the filters")
+	}
+	return pairs, nil
+}
+
+// prebuiltTestPair returns the test pair of a prebuilt test assembly and .app,
+// named after the files (MyApp.UITests.dll, MyApp.app).
+func prebuiltTestPair(testAssemblyPth, appPth string) testPair {
+	nameOf := func(pth string) string {
+		base := filepath.Base(pth)
+		return strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	return testPair{
+		testProjectName: nameOf(testAssemblyPth),
+		projectName:     nameOf(appPth),
+		testDllPth:      testAssemblyPth,
+		appPth:          appPth,
+	}
+}
+
+// sortTestPairs orders the test pairs by the given project names: pairs of the listed test projects or app projects come first,
+// in the order of the list (a pair is ranked by whichever of its projects is listed first), the rest are sorted by test project name then app project name.
+func sortTestPairs(pairs []testPair, order []string) {
+	rank := map[string]int{}
+	for i, name := range order {
+		if _, ok := rank[name]; !ok {
+			rank[name] = i
+		}
+	}
+
+	rankOf := func(name string) int {
+		if r, ok := rank[name]; ok {
+			return r
+		}
+		return len(order)
+	}
+
+	for _, name := range order {
+		found := false
+		for _, pair := range pairs {
+			if pair.testProjectName == name || pair.projectName == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			log.Warnf("Project (%s) in the test order is not tested", name)
+		}
+	}
+
+	pairRank := func(pair testPair) int {
+		testRank, appRank := rankOf(pair.testProjectName), rankOf(pair.projectName)
+		if appRank < testRank {
+			return appRank
+		}
+		return testRank
+	}
+
+	sort.SliceStable(pairs, func(i, j int) bool {
+		a, b := pairs[i], pairs[j]
+		if ra, rb := pairRank(a), pairRank(b); ra != rb {
+			return ra < rb
+		}
+		if a.testProjectName != b.testProjectName {
+			return a.testProjectName < b.testProjectName
+		}
+		return a.projectName < b.projectName
+	})
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bitrise-tools/go-xamarin/builder"
+	"github.com/bitrise-tools/go-xamarin/constants"
+)
+
+func TestBuiltTestPairs(t *testing.T) {
+	testProjectOutputMap := builder.TestProjectOutputMap{
+		"MyApp.UITests": {
+			ReferredProjectNames: []string{"MyApp.iOS", "MyApp.Watch"},
+			Output:               builder.OutputModel{Pth: "/build/MyApp.UITests.dll", OutputType: constants.OutputTypeDLL},
+		},
+		"MyApp.SmokeTests": {
+			ReferredProjectNames: []string{"MyApp.iOS"},
+			Output:               builder.OutputModel{Pth: "/build/MyApp.SmokeTests.dll", OutputType: constants.OutputTypeDLL},
+		},
+	}
+	projectOutputMap := builder.ProjectOutputMap{
+		"MyApp.iOS": {
+			ProjectType: constants.SDKIOS,
+			Outputs:     []builder.OutputModel{{Pth: "/build/MyApp.iOS.app", OutputType: constants.OutputTypeAPP}},
+		},
+		// built by the solution, but without a simulator .app
+		"MyApp.Watch": {
+			ProjectType: constants.SDKIOS,
+			Outputs:     []builder.OutputModel{{Pth: "/build/MyApp.Watch.dll", OutputType: constants.OutputTypeDLL}},
+		},
+	}
+
+	pair := func(testProjectName, projectName string) testPair {
+		return testPair{
+			testProjectName: testProjectName,
+			projectName:     projectName,
+			testDllPth:      "/build/" + testProjectName + ".dll",
+			appPth:          "/build/" + projectName + ".app",
+		}
+	}
+
+	tests := []struct {
+		name              string
+		testProjectFilter nameFilter
+		projectFilter     nameFilter
+		want              []testPair
+		wantErr           bool
+	}{
+		{
+			name:    "no app of a referred project",
+			wantErr: true,
+		},
+		{
+			name:          "excluded app is not looked up",
+			projectFilter: newNameFilter("", "MyApp.Watch"),
+			want:          []testPair{pair("MyApp.SmokeTests", "MyApp.iOS"), pair("MyApp.UITests", "MyApp.iOS")},
+		},
+		{
+			name:              "one test project",
+			testProjectFilter: newNameFilter("*.SmokeTests", ""),
+			want:              []testPair{pair("MyApp.SmokeTests", "MyApp.iOS")},
+		},
+		{
+			name:              "nothing left",
+			testProjectFilter: newNameFilter("MyApp.UITests", ""),
+			projectFilter:     newNameFilter("MyApp.Watch", "MyApp.Watch"),
+			wantErr:           true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := builtTestPairs(testProjectOutputMap, projectOutputMap, tt.testProjectFilter, tt.projectFilter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("builtTestPairs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("builtTestPairs() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortTestPairs(t *testing.T) {
+	pair := func(testProjectName, projectName string) testPair {
+		return testPair{testProjectName: testProjectName, projectName: projectName}
+	}
+	pairs := func() []testPair {
+		return []testPair{
+			pair("MyApp.UITests", "MyApp.iOS"),
+			pair("MyApp.UITests", "MyApp.Lite"),
+			pair("MyApp.SmokeTests", "MyApp.iOS"),
+			pair("MyApp.SmokeTests", "MyApp.Lite"),
+		}
+	}
+
+	tests := []struct {
+		name  string
+		order []string
+		want  []testPair
+	}{
+		{
+			name: "no order",
+			want: []testPair{
+				pair("MyApp.SmokeTests", "MyApp.Lite"),
+				pair("MyApp.SmokeTests", "MyApp.iOS"),
+				pair("MyApp.UITests", "MyApp.Lite"),
+				pair("MyApp.UITests", "MyApp.iOS"),
+			},
+		},
+		{
+			name:  "test projects",
+			order: []string{"MyApp.UITests", "MyApp.SmokeTests"},
+			want: []testPair{
+				pair("MyApp.UITests", "MyApp.Lite"),
+				pair("MyApp.UITests", "MyApp.iOS"),
+				pair("MyApp.SmokeTests", "MyApp.Lite"),
+				pair("MyApp.SmokeTests", "MyApp.iOS"),
+			},
+		},
+		{
+			name:  "app projects",
+			order: []string{"MyApp.iOS"},
+			want: []testPair{
+				pair("MyApp.SmokeTests", "MyApp.iOS"),
+				pair("MyApp.UITests", "MyApp.iOS"),
+				pair("MyApp.SmokeTests", "MyApp.Lite"),
+				pair("MyApp.UITests", "MyApp.Lite"),
+			},
+		},
+		{
+			// the app listed first wins over the test project listed later
+			name:  "app before test project",
+			order: []string{"MyApp.Lite", "MyApp.UITests"},
+			want: []testPair{
+				pair("MyApp.SmokeTests", "MyApp.Lite"),
+				pair("MyApp.UITests", "MyApp.Lite"),
+				pair("MyApp.UITests", "MyApp.iOS"),
+				pair("MyApp.SmokeTests", "MyApp.iOS"),
+			},
+		},
+		{
+			name:  "mixed",
+			order: []string{"MyApp.SmokeTests", "MyApp.iOS", "MyApp.Missing"},
+			want: []testPair{
+				pair("MyApp.SmokeTests", "MyApp.Lite"),
+				pair("MyApp.SmokeTests", "MyApp.iOS"),
+				pair("MyApp.UITests", "MyApp.iOS"),
+				pair("MyApp.UITests", "MyApp.Lite"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pairs()
+			sortTestPairs(got, tt.order)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sortTestPairs() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
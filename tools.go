@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-tools/go-xamarin/constants"
+	"github.com/bitrise-tools/go-xamarin/tools/nunit"
+)
+
+// resolveToolPath returns the absolute path of a tool.
+// The configured value is either a path or a command name looked up in the PATH,
+// if it is empty the default path is used if exists, otherwise the tool name is looked up in the PATH.
+func resolveToolPath(configured, defaultPth, name string) (string, error) {
+	if configured == "" {
+		if exist, err := pathutil.IsPathExists(defaultPth); err != nil {
+			return "", fmt.Errorf("Failed to check if %s exist at (%s), error: %s", name, defaultPth, err)
+		} else if exist {
+			return defaultPth, nil
+		}
+		configured = name
+	}
+
+	if !strings.Contains(configured, string(filepath.Separator)) {
+		pth, err := exec.LookPath(configured)
+		if err != nil {
+			return "", fmt.Errorf("%s not found in PATH, error: %s", configured, err)
+		}
+		return pth, nil
+	}
+
+	pth, err := pathutil.AbsPath(configured)
+	if err != nil {
+		return "", fmt.Errorf("Failed to expand path (%s), error: %s", configured, err)
+	}
+	if exist, err := pathutil.IsPathExists(pth); err != nil {
+		return "", fmt.Errorf("Failed to check if %s exist at (%s), error: %s", name, pth, err)
+	} else if !exist {
+		return "", fmt.Errorf("%s not exist at: %s", name, pth)
+	}
+	return pth, nil
+}
+
+// toolVersion runs the version command of a tool and returns the first (or last) non empty line of its output.
+func toolVersion(lastLine bool, name string, args ...string) (string, error) {
+	cmd := command.New(name, args...)
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s failed, output: %s, error: %s", cmd.PrintableCommandArgs(), out, err)
+	}
+
+	lines := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return "", fmt.Errorf("%s returned empty output", cmd.PrintableCommandArgs())
+	}
+
+	if lastLine {
+		return lines[len(lines)-1], nil
+	}
+	return lines[0], nil
+}
+
+func logToolVersion(name, pth string, version string, err error) {
+	if err != nil {
+		log.Warnf("Failed to detect %s version, error: %s", name, err)
+		log.Printf("%s: %s", name, pth)
+		return
+	}
+	log.Printf("%s: %s (%s)", name, pth, version)
+}
+
+// configureTools resolves the mono and build tool paths and sets them for the builder and nunit console,
+// then returns the nunit3-console.exe path. The build tool is not required in prebuilt mode.
+func configureTools(configs ConfigsModel) (string, error) {
+	monoPth, err := resolveToolPath(configs.MonoPth, constants.MonoPath, "mono")
+	if err != nil {
+		return "", fmt.Errorf("Failed to find mono, error: %s", err)
+	}
+	constants.MonoPath = monoPth
+
+	version, err := toolVersion(false, monoPth, "--version")
+	logToolVersion("mono", monoPth, version, err)
+
+	// prebuilt mode (app_path): nothing is built
+	if configs.AppPth == "" {
+		if err := configureBuildTool(configs); err != nil {
+			return "", err
+		}
+	}
+
+	nunitConsolePth, err := resolveNunitConsolePath(configs)
+	if err != nil {
+		return "", err
+	}
+
+	version, err = toolVersion(false, monoPth, nunitConsolePth, "--version")
+	logToolVersion("nunit3-console", nunitConsolePth, version, err)
+
+	return nunitConsolePth, nil
+}
+
+// configureBuildTool resolves the path of the configured build tool (xbuild or msbuild) and sets it for the builder.
+func configureBuildTool(configs ConfigsModel) error {
+	if configs.BuildTool == "xbuild" {
+		xbuildPth, err := resolveToolPath(configs.XbuildPth, constants.XbuildPath, "xbuild")
+		if err != nil {
+			return fmt.Errorf("Failed to find xbuild, error: %s", err)
+		}
+		constants.XbuildPath = xbuildPth
+
+		version, err := toolVersion(false, xbuildPth, "/version")
+		logToolVersion("xbuild", xbuildPth, version, err)
+	} else {
+		msbuildPth, err := resolveToolPath(configs.MsbuildPth, constants.MsbuildPath, "msbuild")
+		if err != nil {
+			return fmt.Errorf("Failed to find msbuild, error: %s", err)
+		}
+		constants.MsbuildPath = msbuildPth
+
+		version, err := toolVersion(true, msbuildPth, "-version", "-nologo")
+		logToolVersion("msbuild", msbuildPth, version, err)
+	}
+	return nil
+}
+
+// resolveNunitConsolePath returns the configured nunit3-console.exe,
+// or the one restored from NuGet for the solution, or the one in NUNIT_PATH.
+func resolveNunitConsolePath(configs ConfigsModel) (string, error) {
+	if configs.NunitConsolePth != "" {
+		pth, err := resolveToolPath(configs.NunitConsolePth, "", "nunit3-console.exe")
+		if err != nil {
+			return "", fmt.Errorf("Failed to find nunit3-console.exe, error: %s", err)
+		}
+		log.Printf("using the configured nunit3-console.exe (nunit_console_path)")
+		return pth, nil
+	}
+
+	if configs.XamarinSolution != "" {
+		pth, err := discoverNunitConsolePath(configs.XamarinSolution)
+		if err == nil {
+			log.Printf("using the nunit3-console.exe restored from NuGet")
+			return pth, nil
+		}
+		log.Printf("nunit3-console.exe not found in the NuGet packages: %s", err)
+	}
+
+	pth, err := nunit.SystemNunit3ConsolePath()
+	if err != nil {
+		return "", fmt.Errorf("Failed to get system insatlled nunit3-console.exe path, error: %s", err)
+	}
+	log.Printf("using the nunit3-console.exe in NUNIT_PATH")
+	return pth, nil
+}
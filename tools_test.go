@@ -0,0 +1,138 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-tools/go-xamarin/constants"
+)
+
+// writeStubTool writes an executable shell script, which prints the given output.
+func writeStubTool(t *testing.T, dir, name, output string) string {
+	pth := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(pth, []byte("#!/bin/sh\nprintf '"+output+"'\n"), 0755); err != nil {
+		t.Fatalf("Failed to write stub %s, error: %s", name, err)
+	}
+	return pth
+}
+
+func TestResolveToolPath(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "tools")
+	if err != nil {
+		t.Fatalf("Failed to create tmp dir, error: %s", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove tmp dir, error: %s", err)
+		}
+	}()
+
+	monoPth := writeStubTool(t, tmpDir, "mono", "")
+	binDir := filepath.Join(tmpDir, "bin")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		t.Fatalf("Failed to create bin dir, error: %s", err)
+	}
+	pathMonoPth := writeStubTool(t, binDir, "mono", "")
+
+	originalPath := os.Getenv("PATH")
+	if err := os.Setenv("PATH", binDir); err != nil {
+		t.Fatalf("Failed to set PATH, error: %s", err)
+	}
+	defer func() {
+		if err := os.Setenv("PATH", originalPath); err != nil {
+			t.Errorf("Failed to restore PATH, error: %s", err)
+		}
+	}()
+
+	tests := []struct {
+		name       string
+		configured string
+		defaultPth string
+		want       string
+		wantErr    bool
+	}{
+		{name: "default path", defaultPth: monoPth, want: monoPth},
+		{name: "missing default path falls back to PATH", defaultPth: filepath.Join(tmpDir, "missing"), want: pathMonoPth},
+		{name: "configured path", configured: monoPth, defaultPth: pathMonoPth, want: monoPth},
+		{name: "configured command name", configured: "mono", defaultPth: monoPth, want: pathMonoPth},
+		{name: "configured path not exist", configured: filepath.Join(tmpDir, "missing"), wantErr: true},
+		{name: "configured command not in PATH", configured: "mono-5.10", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveToolPath(tt.configured, tt.defaultPth, "mono")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveToolPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveToolPath() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToolVersion(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "tools")
+	if err != nil {
+		t.Fatalf("Failed to create tmp dir, error: %s", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove tmp dir, error: %s", err)
+		}
+	}()
+
+	monoPth := writeStubTool(t, tmpDir, "mono", `Mono JIT compiler version 5.10.1.47 (2017-12/8eb8f7d5e74 Fri Apr 13 20:18:12 EDT 2018)\nCopyright (C) 2002-2014 Novell, Inc\n`)
+	msbuildPth := writeStubTool(t, tmpDir, "msbuild", `\nMicrosoft (R) Build Engine version 15.6.0.0\n15.6.0.0\n\n`)
+	emptyPth := writeStubTool(t, tmpDir, "empty", `\n  \n`)
+
+	if version, err := toolVersion(false, monoPth, "--version"); err != nil || version != "Mono JIT compiler version 5.10.1.47 (2017-12/8eb8f7d5e74 Fri Apr 13 20:18:12 EDT 2018)" {
+		t.Errorf("mono version = %s, error: %v", version, err)
+	}
+	if version, err := toolVersion(true, msbuildPth, "-version", "-nologo"); err != nil || version != "15.6.0.0" {
+		t.Errorf("msbuild version = %s, error: %v", version, err)
+	}
+	if _, err := toolVersion(false, emptyPth); err == nil {
+		t.Errorf("toolVersion() expected error for an empty output")
+	}
+	if _, err := toolVersion(false, filepath.Join(tmpDir, "missing")); err == nil {
+		t.Errorf("toolVersion() expected error for a missing tool")
+	}
+}
+
+func TestConfigureToolsPrebuilt(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "tools")
+	if err != nil {
+		t.Fatalf("Failed to create tmp dir, error: %s", err)
+	}
+	originalMonoPath, originalMsbuildPath := constants.MonoPath, constants.MsbuildPath
+	defer func() {
+		constants.MonoPath, constants.MsbuildPath = originalMonoPath, originalMsbuildPath
+		if err := os.RemoveAll(tmpDir); err != nil {
+			t.Errorf("Failed to remove tmp dir, error: %s", err)
+		}
+	}()
+
+	configs := ConfigsModel{
+		MonoPth:         writeStubTool(t, tmpDir, "mono", `Mono JIT compiler version 5.10.1.47\n`),
+		NunitConsolePth: writeStubTool(t, tmpDir, "nunit3-console.exe", ""),
+		BuildTool:       "msbuild",
+		MsbuildPth:      filepath.Join(tmpDir, "missing", "msbuild"),
+	}
+
+	if _, err := configureTools(configs); err == nil {
+		t.Errorf("configureTools() expected error for a missing msbuild")
+	}
+
+	configs.AppPth = filepath.Join(tmpDir, "CreditCardValidator.iOS.app")
+	nunitConsolePth, err := configureTools(configs)
+	if err != nil {
+		t.Fatalf("configureTools() in prebuilt mode error: %s", err)
+	}
+	if nunitConsolePth != configs.NunitConsolePth || constants.MonoPath != configs.MonoPth {
+		t.Errorf("configureTools() = %s, mono: %s", nunitConsolePth, constants.MonoPath)
+	}
+}